@@ -0,0 +1,230 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	CreatedAt = "createdAt"
+	CreatedBy = "createdBy"
+	UpdatedAt = "updatedAt"
+	UpdatedBy = "updatedBy"
+)
+
+type contextKey string
+
+// userKey is the context key of the user id used by the default Audit.GetUser.
+const userKey contextKey = "userId"
+
+// WithUser returns a context with the user id which is written to the audit fields.
+func WithUser(ctx context.Context, userId string) context.Context {
+	return context.WithValue(ctx, userKey, userId)
+}
+
+type Audit struct {
+	// CreatedAt, CreatedBy, UpdatedAt and UpdatedBy are the struct field names of the model, an empty name is not audited.
+	CreatedAt string
+	CreatedBy string
+	UpdatedAt string
+	UpdatedBy string
+	Now       func() time.Time
+	GetUser   func(ctx context.Context) string
+}
+
+// NewAudit builds the audit from the model fields tagged `audit:"createdAt"`, `audit:"createdBy"`, `audit:"updatedAt"` or `audit:"updatedBy"`; it returns nil when there is no audit field.
+func NewAudit(modelType reflect.Type) *Audit {
+	audit := &Audit{}
+	found := false
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		tag, ok := field.Tag.Lookup("audit")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(tag) {
+		case CreatedAt:
+			audit.CreatedAt = field.Name
+		case CreatedBy:
+			audit.CreatedBy = field.Name
+		case UpdatedAt:
+			audit.UpdatedAt = field.Name
+		case UpdatedBy:
+			audit.UpdatedBy = field.Name
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return audit
+}
+
+func (a *Audit) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+func (a *Audit) user(ctx context.Context) string {
+	if a.GetUser != nil {
+		return a.GetUser(ctx)
+	}
+	if u, ok := ctx.Value(userKey).(string); ok {
+		return u
+	}
+	return ""
+}
+
+// Protected returns the attribute names which must not be overwritten once created.
+func (a *Audit) Protected(modelType reflect.Type) []string {
+	var names []string
+	for _, fieldName := range []string{a.CreatedAt, a.CreatedBy} {
+		if len(fieldName) == 0 {
+			continue
+		}
+		if _, name, ok := GetFieldByName(modelType, fieldName); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// OnCreate sets all audit fields of the model; a struct passed by value is copied, so the returned model must be written.
+func (a *Audit) OnCreate(ctx context.Context, model interface{}) (interface{}, error) {
+	v, model := addressable(model)
+	now, user := a.now(), a.user(ctx)
+	if err := setAuditField(v, a.CreatedAt, now, false); err != nil {
+		return model, err
+	}
+	if err := setAuditField(v, a.CreatedBy, user, false); err != nil {
+		return model, err
+	}
+	if err := setAuditField(v, a.UpdatedAt, now, false); err != nil {
+		return model, err
+	}
+	return model, setAuditField(v, a.UpdatedBy, user, false)
+}
+
+// OnUpdate sets the updated fields, the created fields are only set when they are empty because the stored values are kept.
+func (a *Audit) OnUpdate(ctx context.Context, model interface{}) (interface{}, error) {
+	v, model := addressable(model)
+	now, user := a.now(), a.user(ctx)
+	if err := setAuditField(v, a.CreatedAt, now, true); err != nil {
+		return model, err
+	}
+	if err := setAuditField(v, a.CreatedBy, user, true); err != nil {
+		return model, err
+	}
+	if err := setAuditField(v, a.UpdatedAt, now, false); err != nil {
+		return model, err
+	}
+	return model, setAuditField(v, a.UpdatedBy, user, false)
+}
+
+// OnPatch sets the updated attributes of a patch and removes the created attributes from it.
+func (a *Audit) OnPatch(ctx context.Context, modelType reflect.Type, model map[string]interface{}) (map[string]interface{}, error) {
+	for _, name := range a.Protected(modelType) {
+		delete(model, name)
+	}
+	if err := setAuditAttribute(modelType, model, a.UpdatedAt, a.now()); err != nil {
+		return model, err
+	}
+	return model, setAuditAttribute(modelType, model, a.UpdatedBy, a.user(ctx))
+}
+
+func addressable(model interface{}) (reflect.Value, interface{}) {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		return v.Elem(), model
+	}
+	c := reflect.New(v.Type())
+	c.Elem().Set(v)
+	return c.Elem(), c.Interface()
+}
+
+func setAuditField(model reflect.Value, fieldName string, value interface{}, onlyEmpty bool) error {
+	if len(fieldName) == 0 || model.Kind() != reflect.Struct {
+		return nil
+	}
+	field := model.FieldByName(fieldName)
+	if !field.IsValid() || !field.CanSet() {
+		return fmt.Errorf("cannot set audit field %s", fieldName)
+	}
+	if onlyEmpty && !field.IsZero() {
+		return nil
+	}
+	v, err := auditValue(field.Type(), value)
+	if err != nil {
+		return fmt.Errorf("%s: %s", fieldName, err.Error())
+	}
+	field.Set(v)
+	return nil
+}
+
+func setAuditAttribute(modelType reflect.Type, model map[string]interface{}, fieldName string, value interface{}) error {
+	if len(fieldName) == 0 {
+		return nil
+	}
+	field, ok := modelType.FieldByName(fieldName)
+	if !ok {
+		return fmt.Errorf("cannot set audit field %s", fieldName)
+	}
+	_, name, _ := GetFieldByName(modelType, fieldName)
+	if t, ok := value.(time.Time); ok && isUnixTime(field) {
+		model[name] = dynamodbattribute.UnixTime(t)
+		return nil
+	}
+	v, err := auditValue(field.Type, value)
+	if err != nil {
+		return fmt.Errorf("%s: %s", fieldName, err.Error())
+	}
+	model[name] = v.Interface()
+	return nil
+}
+
+// auditValue converts a time or a user id to the type of the field: time, string, epoch seconds or a pointer to one of them.
+func auditValue(fieldType reflect.Type, value interface{}) (reflect.Value, error) {
+	if fieldType.Kind() == reflect.Ptr {
+		v, err := auditValue(fieldType.Elem(), value)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(fieldType.Elem())
+		p.Elem().Set(v)
+		return p, nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Type().ConvertibleTo(fieldType) {
+		return v.Convert(fieldType), nil
+	}
+	if t, ok := value.(time.Time); ok {
+		switch fieldType.Kind() {
+		case reflect.String:
+			return reflect.ValueOf(t.Format(time.RFC3339Nano)).Convert(fieldType), nil
+		case reflect.Int64, reflect.Int:
+			return reflect.ValueOf(t.Unix()).Convert(fieldType), nil
+		}
+	}
+	return v, fmt.Errorf("audit field does not support type %s", fieldType.String())
+}
+
+func isUnixTime(field reflect.StructField) bool {
+	if tag, ok := field.Tag.Lookup("dynamodbav"); ok {
+		options := strings.Split(tag, ",")
+		for i := 1; i < len(options); i++ {
+			if strings.TrimSpace(options[i]) == "unixtime" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type note struct {
+	Id        string     `json:"id" dynamodbav:"id"`
+	Text      string     `json:"text" dynamodbav:"text"`
+	CreatedAt *time.Time `json:"createdAt" dynamodbav:"createdAt" audit:"createdAt"`
+	CreatedBy string     `json:"createdBy" dynamodbav:"createdBy" audit:"createdBy"`
+	UpdatedAt int64      `json:"updatedAt" dynamodbav:"updatedAt,unixtime" audit:"updatedAt"`
+	UpdatedBy *string    `json:"updatedBy" dynamodbav:"updatedBy" audit:"updatedBy"`
+}
+
+func testAudit() *Audit {
+	audit := NewAudit(reflect.TypeOf(note{}))
+	audit.Now = func() time.Time {
+		return time.Unix(1700000000, 0).UTC()
+	}
+	return audit
+}
+
+func TestNewAudit(t *testing.T) {
+	audit := NewAudit(reflect.TypeOf(note{}))
+	want := &Audit{CreatedAt: "CreatedAt", CreatedBy: "CreatedBy", UpdatedAt: "UpdatedAt", UpdatedBy: "UpdatedBy"}
+	if !reflect.DeepEqual(audit, want) {
+		t.Errorf("NewAudit = %+v, want %+v", audit, want)
+	}
+	if audit := NewAudit(reflect.TypeOf(account{})); audit != nil {
+		t.Errorf("NewAudit = %+v without audit field, want nil", audit)
+	}
+	if protected := audit.Protected(reflect.TypeOf(note{})); !reflect.DeepEqual(protected, []string{"createdAt", "createdBy"}) {
+		t.Errorf("Protected = %v, want [createdAt createdBy]", protected)
+	}
+}
+
+func TestAuditOnCreateAndUpdate(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	created := time.Unix(1600000000, 0).UTC()
+	tests := []struct {
+		name      string
+		create    bool
+		model     interface{}
+		user      string
+		createdAt time.Time
+		createdBy string
+	}{
+		{"create a pointer", true, &note{Id: "n1"}, "u1", now, "u1"},
+		{"create a value", true, note{Id: "n1"}, "u1", now, "u1"},
+		{"create overwrites the created fields", true, &note{Id: "n1", CreatedAt: &created, CreatedBy: "u0"}, "u1", now, "u1"},
+		{"update sets empty created fields", false, &note{Id: "n1"}, "u1", now, "u1"},
+		{"update keeps the created fields", false, note{Id: "n1", CreatedAt: &created, CreatedBy: "u0"}, "u1", created, "u0"},
+		{"no user", false, &note{Id: "n1"}, "", now, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := testAudit()
+			ctx := context.Background()
+			if len(tt.user) > 0 {
+				ctx = WithUser(ctx, tt.user)
+			}
+			var model interface{}
+			var err error
+			if tt.create {
+				model, err = audit.OnCreate(ctx, tt.model)
+			} else {
+				model, err = audit.OnUpdate(ctx, tt.model)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, ok := model.(*note)
+			if !ok {
+				t.Fatalf("model = %T, want *note", model)
+			}
+			if n.CreatedAt == nil || !n.CreatedAt.Equal(tt.createdAt) || n.CreatedBy != tt.createdBy {
+				t.Errorf("created = %v, %q, want %v, %q", n.CreatedAt, n.CreatedBy, tt.createdAt, tt.createdBy)
+			}
+			if n.UpdatedAt != now.Unix() || n.UpdatedBy == nil || *n.UpdatedBy != tt.user {
+				t.Errorf("updated = %v, %v, want %v, %q", n.UpdatedAt, n.UpdatedBy, now.Unix(), tt.user)
+			}
+		})
+	}
+}
+
+func TestAuditOnPatch(t *testing.T) {
+	audit := testAudit()
+	audit.GetUser = func(ctx context.Context) string {
+		return "system"
+	}
+	model, err := audit.OnPatch(WithUser(context.Background(), "u1"), reflect.TypeOf(note{}), map[string]interface{}{"id": "n1", "text": "hello", "createdBy": "u0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model["createdBy"]; ok {
+		t.Error("patch writes the created attributes")
+	}
+	if v, ok := model["updatedAt"].(dynamodbattribute.UnixTime); !ok || time.Time(v).Unix() != 1700000000 {
+		t.Errorf("updatedAt = %v, want the unix time", model["updatedAt"])
+	}
+	if v, ok := model["updatedBy"].(*string); !ok || *v != "system" {
+		t.Errorf("updatedBy = %v, want the user of GetUser", model["updatedBy"])
+	}
+}
+
+func TestWriterUpdateKeepsCreatedFields(t *testing.T) {
+	stored := map[string]*dynamodb.AttributeValue{
+		"id":        {S: aws.String("n1")},
+		"createdAt": {S: aws.String("2020-09-13T12:26:40Z")},
+		"createdBy": {S: aws.String("u0")},
+	}
+	db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		if r.Operation == "GetItem" {
+			return &dynamodb.GetItemOutput{Item: stored}
+		}
+		return nil
+	})
+	w := NewWriter(db, "notes", reflect.TypeOf(note{}), "Id", "")
+	w.Audit.Now = testAudit().Now
+	if _, err := w.Update(WithUser(context.Background(), "u1"), &note{Id: "n1", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fake.Requests("UpdateItem")); n > 0 {
+		t.Errorf("%d UpdateItem, want a PutItem", n)
+	}
+	puts := fake.Requests("PutItem")
+	if len(puts) != 1 {
+		t.Fatalf("%d PutItem, want 1", len(puts))
+	}
+	var input dynamodb.PutItemInput
+	puts[0].Decode(t, &input)
+	var n note
+	if err := dynamodbattribute.UnmarshalMap(input.Item, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.CreatedAt == nil || n.CreatedAt.Unix() != 1600000000 || n.CreatedBy != "u0" {
+		t.Errorf("created = %v, %q, want the stored values", n.CreatedAt, n.CreatedBy)
+	}
+	if n.Text != "hello" || n.UpdatedAt != 1700000000 || n.UpdatedBy == nil || *n.UpdatedBy != "u1" {
+		t.Errorf("item = %+v, want the new text and updated fields", n)
+	}
+	if condition := writeCondition(t, fake); condition != "((attribute_exists (id)) AND (createdAt = :0)) AND (createdBy = :1)" {
+		t.Errorf("condition = %q, want the protected attributes unchanged", condition)
+	}
+}
@@ -0,0 +1,227 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"reflect"
+	"strings"
+)
+
+const (
+	StringSet = "stringset"
+	NumberSet = "numberset"
+	BinarySet = "binaryset"
+)
+
+// attributeValue lets an already built AttributeValue pass through dynamodbattribute and expression.Value unchanged.
+type attributeValue struct {
+	value *dynamodb.AttributeValue
+}
+
+func (a attributeValue) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	*av = *a.value
+	return nil
+}
+
+func AddToSet(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, name string, values interface{}) (int64, error) {
+	set, err := BuildSet(values)
+	if err != nil {
+		return 0, err
+	}
+	return UpdateWithExpression(ctx, db, tableName, keys, id, expression.Add(expression.Name(name), expression.Value(set)))
+}
+
+func DeleteFromSet(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, name string, values interface{}) (int64, error) {
+	set, err := BuildSet(values)
+	if err != nil {
+		return 0, err
+	}
+	return UpdateWithExpression(ctx, db, tableName, keys, id, expression.Delete(expression.Name(name), expression.Value(set)))
+}
+
+func AppendToList(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, name string, values interface{}) (int64, error) {
+	list, err := BuildList(values)
+	if err != nil {
+		return 0, err
+	}
+	field := expression.Name(name)
+	current := field.IfNotExists(expression.Value(emptyList()))
+	return UpdateWithExpression(ctx, db, tableName, keys, id, expression.Set(field, expression.ListAppend(current, expression.Value(list))))
+}
+
+func PrependToList(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, name string, values interface{}) (int64, error) {
+	list, err := BuildList(values)
+	if err != nil {
+		return 0, err
+	}
+	field := expression.Name(name)
+	current := field.IfNotExists(expression.Value(emptyList()))
+	return UpdateWithExpression(ctx, db, tableName, keys, id, expression.Set(field, expression.ListAppend(expression.Value(list), current)))
+}
+
+func RemoveFromList(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, name string, indexes ...int) (int64, error) {
+	if len(indexes) == 0 {
+		return 0, fmt.Errorf("no index to remove from list %s", name)
+	}
+	var update expression.UpdateBuilder
+	for _, index := range indexes {
+		if index < 0 {
+			return 0, fmt.Errorf("invalid list index: %d", index)
+		}
+		update = update.Remove(expression.Name(fmt.Sprintf("%s[%d]", name, index)))
+	}
+	return UpdateWithExpression(ctx, db, tableName, keys, id, update)
+}
+
+func UpdateWithExpression(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, update expression.UpdateBuilder, options ...WriteOptions) (int64, error) {
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("cannot update an object without keys")
+	}
+	keyMap, err := buildKeyMap(keys, id)
+	if err != nil {
+		return 0, err
+	}
+	opts := getWriteOptions(options)
+	return updateItem(ctx, db, tableName, keyMap, update, And(existsCondition(keys, opts), opts.Condition), updateFailed, opts)
+}
+
+// BuildSet builds a string, number or binary set from a slice (or a single value); the set type is inferred from the elements.
+func BuildSet(values interface{}) (*dynamodb.AttributeValue, error) {
+	return buildSet(values, "")
+}
+
+func buildSet(values interface{}, setType string) (*dynamodb.AttributeValue, error) {
+	if av, ok := values.(*dynamodb.AttributeValue); ok && (av.SS != nil || av.NS != nil || av.BS != nil) {
+		return av, nil
+	}
+	elements, err := BuildList(values)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements.L) == 0 {
+		return nil, fmt.Errorf("set cannot be empty")
+	}
+	set := &dynamodb.AttributeValue{}
+	for _, e := range elements.L {
+		if setType == BinarySet && e.S != nil {
+			b, err := base64.StdEncoding.DecodeString(*e.S)
+			if err != nil {
+				return nil, err
+			}
+			e = &dynamodb.AttributeValue{B: b}
+		}
+		switch {
+		case e.S != nil && set.NS == nil && set.BS == nil:
+			set.SS = append(set.SS, e.S)
+		case e.N != nil && set.SS == nil && set.BS == nil:
+			set.NS = append(set.NS, e.N)
+		case e.B != nil && set.SS == nil && set.NS == nil:
+			set.BS = append(set.BS, e.B)
+		default:
+			return nil, fmt.Errorf("set elements must be all strings, all numbers or all binaries")
+		}
+	}
+	if (setType == StringSet && set.SS == nil) || (setType == NumberSet && set.NS == nil) || (setType == BinarySet && set.BS == nil) {
+		return nil, fmt.Errorf("values do not match %s", setType)
+	}
+	return set, nil
+}
+
+// BuildList builds a list attribute from a slice; a single value becomes a list of one element.
+func BuildList(values interface{}) (*dynamodb.AttributeValue, error) {
+	v := reflect.Indirect(reflect.ValueOf(values))
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		values = []interface{}{values}
+		v = reflect.ValueOf(values)
+	}
+	list := &dynamodb.AttributeValue{L: make([]*dynamodb.AttributeValue, 0, v.Len())}
+	for i := 0; i < v.Len(); i++ {
+		e, err := dynamodbattribute.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		list.L = append(list.L, e)
+	}
+	return list, nil
+}
+
+func emptyList() *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+}
+
+func GetSetType(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("dynamodbav"); ok {
+		options := strings.Split(tag, ",")
+		for i := 1; i < len(options); i++ {
+			switch strings.TrimSpace(options[i]) {
+			case StringSet, NumberSet, BinarySet:
+				return strings.TrimSpace(options[i])
+			}
+		}
+	}
+	return ""
+}
+
+// MakeSetFields maps the attribute name of every set field of the model to its set type.
+func MakeSetFields(modelType reflect.Type) map[string]string {
+	sets := make(map[string]string)
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		if setType := GetSetType(field); len(setType) > 0 {
+			fieldName, name, _ := GetFieldByIndex(modelType, i)
+			if len(name) == 0 {
+				name = fieldName
+			}
+			sets[name] = setType
+		}
+	}
+	return sets
+}
+
+// MapSetValues converts the values of set fields, usually decoded from JSON as lists, into DynamoDB sets.
+// An empty list becomes nil, because DynamoDB has no empty set; see RemoveEmptySets.
+func MapSetValues(model map[string]interface{}, sets map[string]string) (map[string]interface{}, error) {
+	if len(sets) == 0 {
+		return model, nil
+	}
+	for name, value := range model {
+		setType, ok := sets[name]
+		if !ok || value == nil {
+			continue
+		}
+		if _, ok := value.(attributeValue); ok {
+			continue
+		}
+		v := reflect.Indirect(reflect.ValueOf(value))
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() == 0 {
+			model[name] = nil
+			continue
+		}
+		set, err := buildSet(value, setType)
+		if err != nil {
+			return model, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		model[name] = attributeValue{set}
+	}
+	return model, nil
+}
+
+// RemoveEmptySets moves the nil set fields out of the model and returns them, so that a patch removes them instead of writing NULL.
+func RemoveEmptySets(model map[string]interface{}, sets map[string]string) map[string]interface{} {
+	var removed map[string]interface{}
+	for name := range sets {
+		if value, ok := model[name]; ok && value == nil {
+			if removed == nil {
+				removed = make(map[string]interface{})
+			}
+			removed[name] = nil
+			delete(model, name)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,347 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"strings"
+)
+
+const (
+	ObjectExist          = "object exist"
+	ObjectNotFound       = "object not found"
+	WrongVersion         = "wrong version"
+	ConditionCheckFailed = "condition check failed"
+)
+
+type WriteOptions struct {
+	// Condition is ANDed with the key existence and version conditions of the operation.
+	Condition *expression.ConditionBuilder
+	// ReturnValuesOnConditionCheckFailure attaches the current item to the ConditionalCheckFailedError.
+	ReturnValuesOnConditionCheckFailure bool
+	// ReturnValues is dynamodb.ReturnValueAllOld, ReturnValueAllNew, ReturnValueUpdatedOld or ReturnValueUpdatedNew.
+	// The new image of a put is the item itself; a delete only supports ReturnValueNone and ReturnValueAllOld.
+	ReturnValues string
+	// Result receives the item image requested by ReturnValues.
+	Result interface{}
+	// Messages are written to the Outbox in the same transaction as the item; ReturnValues is not supported with them.
+	Messages []OutboxMessage
+	Outbox   *Outbox
+	// Attributes are written with the item, such as the normalized shadow attributes; a nil value is not written by a put and is removed by an update.
+	Attributes map[string]interface{}
+	// SoftDelete makes a soft deleted item count as missing: updates and patches fail as not found, and inserts replace it.
+	SoftDelete *SoftDelete
+}
+
+type ConditionalCheckFailedError struct {
+	Message string
+	Item    map[string]*dynamodb.AttributeValue
+}
+
+func (e *ConditionalCheckFailedError) Error() string {
+	return e.Message
+}
+
+// Decode unmarshals the item, which was stored when the condition failed, into result.
+func (e *ConditionalCheckFailedError) Decode(result interface{}) (bool, error) {
+	if len(e.Item) == 0 {
+		return false, nil
+	}
+	err := dynamodbattribute.UnmarshalMap(e.Item, result)
+	return err == nil, err
+}
+
+func IsConditionalCheckFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(*ConditionalCheckFailedError); ok {
+		return true
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+	}
+	return strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0
+}
+
+// And combines the non nil conditions; it returns nil when there is no condition.
+func And(conditions ...*expression.ConditionBuilder) *expression.ConditionBuilder {
+	var result *expression.ConditionBuilder
+	for _, c := range conditions {
+		if c == nil {
+			continue
+		}
+		if result == nil {
+			c2 := *c
+			result = &c2
+		} else {
+			and := result.And(*c)
+			result = &and
+		}
+	}
+	return result
+}
+
+func getWriteOptions(options []WriteOptions) WriteOptions {
+	if len(options) > 0 {
+		return options[0]
+	}
+	return WriteOptions{}
+}
+
+func keyExists(keys []string) *expression.ConditionBuilder {
+	if len(keys) == 0 {
+		return nil
+	}
+	c := expression.AttributeExists(expression.Name(keys[0]))
+	return &c
+}
+
+func keyNotExists(keys []string) *expression.ConditionBuilder {
+	if len(keys) == 0 {
+		return nil
+	}
+	c := expression.AttributeNotExists(expression.Name(keys[0]))
+	return &c
+}
+
+// existsCondition is the key existence condition, which also excludes the soft deleted items.
+func existsCondition(keys []string, options WriteOptions) *expression.ConditionBuilder {
+	if options.SoftDelete == nil {
+		return keyExists(keys)
+	}
+	notDeleted := options.SoftDelete.NotDeleted()
+	return And(keyExists(keys), &notDeleted)
+}
+
+// notExistsCondition is the key absence condition, a soft deleted item counts as absent.
+func notExistsCondition(keys []string, options WriteOptions) *expression.ConditionBuilder {
+	c := keyNotExists(keys)
+	if c == nil || options.SoftDelete == nil {
+		return c
+	}
+	or := c.Or(expression.Not(options.SoftDelete.NotDeleted()))
+	return &or
+}
+
+func versionEqual(versionField string, version interface{}) *expression.ConditionBuilder {
+	c := expression.Name(versionField).Equal(expression.Value(version))
+	return &c
+}
+
+// insertFailed explains a failed insert from the stored item: the key exists or the user condition is false.
+func insertFailed(item map[string]*dynamodb.AttributeValue) string {
+	if len(item) > 0 {
+		return ObjectExist
+	}
+	return ConditionCheckFailed
+}
+
+func updateFailed(item map[string]*dynamodb.AttributeValue) string {
+	if len(item) == 0 {
+		return ObjectNotFound
+	}
+	return ConditionCheckFailed
+}
+
+func conditionFailed(item map[string]*dynamodb.AttributeValue) string {
+	return ConditionCheckFailed
+}
+
+func versionFailed(versionField string, version int64) func(map[string]*dynamodb.AttributeValue) string {
+	return func(item map[string]*dynamodb.AttributeValue) string {
+		if len(item) == 0 {
+			return ObjectNotFound
+		}
+		if v, ok := item[versionField]; !ok || v.N == nil || *v.N != fmt.Sprintf("%d", version) {
+			return WrongVersion
+		}
+		return ConditionCheckFailed
+	}
+}
+
+func toWriteError(err error, options WriteOptions, message func(map[string]*dynamodb.AttributeValue) string) error {
+	if !IsConditionalCheckFailed(err) {
+		return err
+	}
+	var item map[string]*dynamodb.AttributeValue
+	if e, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
+		item = e.Item
+	}
+	stored := item
+	if options.SoftDelete != nil && options.SoftDelete.IsDeleted(item) {
+		stored = nil
+	}
+	e := &ConditionalCheckFailedError{Message: message(stored)}
+	if options.ReturnValuesOnConditionCheckFailure {
+		e.Item = item
+	}
+	return e
+}
+
+func capacityUnits(capacity *dynamodb.ConsumedCapacity) int64 {
+	if capacity == nil {
+		return 0
+	}
+	return int64(aws.Float64Value(capacity.CapacityUnits))
+}
+
+func isOldImage(returnValues string) bool {
+	return returnValues == dynamodb.ReturnValueAllOld || returnValues == dynamodb.ReturnValueUpdatedOld
+}
+
+func isNewImage(returnValues string) bool {
+	return returnValues == dynamodb.ReturnValueAllNew || returnValues == dynamodb.ReturnValueUpdatedNew
+}
+
+func decodeReturnValues(attributes map[string]*dynamodb.AttributeValue, options WriteOptions) error {
+	if options.Result == nil || len(attributes) == 0 {
+		return nil
+	}
+	return dynamodbattribute.UnmarshalMap(attributes, options.Result)
+}
+
+func putItem(ctx context.Context, db *dynamodb.DynamoDB, tableName string, item map[string]*dynamodb.AttributeValue, condition *expression.ConditionBuilder, message func(map[string]*dynamodb.AttributeValue) string, options WriteOptions) (int64, error) {
+	for name, value := range options.Attributes {
+		if value == nil {
+			delete(item, name)
+			continue
+		}
+		v, err := dynamodbattribute.Marshal(value)
+		if err != nil {
+			return 0, err
+		}
+		item[name] = v
+	}
+	params := &dynamodb.PutItemInput{
+		TableName:              aws.String(tableName),
+		Item:                   item,
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	if isOldImage(options.ReturnValues) {
+		params.ReturnValues = aws.String(dynamodb.ReturnValueAllOld)
+	}
+	if condition != nil {
+		expr, err := expression.NewBuilder().WithCondition(*condition).Build()
+		if err != nil {
+			return 0, err
+		}
+		params.ConditionExpression = expr.Condition()
+		params.ExpressionAttributeNames = expr.Names()
+		params.ExpressionAttributeValues = expr.Values()
+		params.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+	if len(options.Messages) > 0 {
+		return transactWrite(ctx, db, &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+			TableName:                           params.TableName,
+			Item:                                params.Item,
+			ConditionExpression:                 params.ConditionExpression,
+			ExpressionAttributeNames:            params.ExpressionAttributeNames,
+			ExpressionAttributeValues:           params.ExpressionAttributeValues,
+			ReturnValuesOnConditionCheckFailure: params.ReturnValuesOnConditionCheckFailure,
+		}}, message, options)
+	}
+	output, err := db.PutItemWithContext(ctx, params)
+	if err != nil {
+		return 0, toWriteError(err, options, message)
+	}
+	if isNewImage(options.ReturnValues) {
+		err = decodeReturnValues(item, options)
+	} else {
+		err = decodeReturnValues(output.Attributes, options)
+	}
+	return capacityUnits(output.ConsumedCapacity), err
+}
+
+func updateItem(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keyMap map[string]*dynamodb.AttributeValue, update expression.UpdateBuilder, condition *expression.ConditionBuilder, message func(map[string]*dynamodb.AttributeValue) string, options WriteOptions) (int64, error) {
+	for name, value := range options.Attributes {
+		if value == nil {
+			update = update.Remove(expression.Name(name))
+		} else {
+			update = update.Set(expression.Name(name), expression.Value(value))
+		}
+	}
+	builder := expression.NewBuilder().WithUpdate(update)
+	if condition != nil {
+		builder = builder.WithCondition(*condition)
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return 0, err
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       keyMap,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ReturnConsumedCapacity:    aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	if condition != nil {
+		input.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+	if len(options.Messages) > 0 {
+		return transactWrite(ctx, db, &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+			TableName:                           input.TableName,
+			Key:                                 input.Key,
+			UpdateExpression:                    input.UpdateExpression,
+			ConditionExpression:                 input.ConditionExpression,
+			ExpressionAttributeNames:            input.ExpressionAttributeNames,
+			ExpressionAttributeValues:           input.ExpressionAttributeValues,
+			ReturnValuesOnConditionCheckFailure: input.ReturnValuesOnConditionCheckFailure,
+		}}, message, options)
+	}
+	if len(options.ReturnValues) > 0 {
+		input.ReturnValues = aws.String(options.ReturnValues)
+	}
+	output, err := db.UpdateItemWithContext(ctx, input)
+	if err != nil {
+		return 0, toWriteError(err, options, message)
+	}
+	return capacityUnits(output.ConsumedCapacity), decodeReturnValues(output.Attributes, options)
+}
+
+func deleteItem(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keyMap map[string]*dynamodb.AttributeValue, condition *expression.ConditionBuilder, message func(map[string]*dynamodb.AttributeValue) string, options WriteOptions) (int64, error) {
+	params := &dynamodb.DeleteItemInput{
+		TableName:              aws.String(tableName),
+		Key:                    keyMap,
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	switch options.ReturnValues {
+	case "", dynamodb.ReturnValueNone:
+	case dynamodb.ReturnValueAllOld:
+		params.ReturnValues = aws.String(dynamodb.ReturnValueAllOld)
+	default:
+		return 0, fmt.Errorf("delete does not support ReturnValues %s", options.ReturnValues)
+	}
+	if condition != nil {
+		expr, err := expression.NewBuilder().WithCondition(*condition).Build()
+		if err != nil {
+			return 0, err
+		}
+		params.ConditionExpression = expr.Condition()
+		params.ExpressionAttributeNames = expr.Names()
+		params.ExpressionAttributeValues = expr.Values()
+		params.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+	if len(options.Messages) > 0 {
+		return transactWrite(ctx, db, &dynamodb.TransactWriteItem{Delete: &dynamodb.Delete{
+			TableName:                           params.TableName,
+			Key:                                 params.Key,
+			ConditionExpression:                 params.ConditionExpression,
+			ExpressionAttributeNames:            params.ExpressionAttributeNames,
+			ExpressionAttributeValues:           params.ExpressionAttributeValues,
+			ReturnValuesOnConditionCheckFailure: params.ReturnValuesOnConditionCheckFailure,
+		}}, message, options)
+	}
+	output, err := db.DeleteItemWithContext(ctx, params)
+	if err != nil {
+		return 0, toWriteError(err, options, message)
+	}
+	return capacityUnits(output.ConsumedCapacity), decodeReturnValues(output.Attributes, options)
+}
@@ -0,0 +1,253 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"testing"
+)
+
+type account struct {
+	Id      string `json:"id" dynamodbav:"id"`
+	Status  string `json:"status" dynamodbav:"status"`
+	Version int64  `json:"version" dynamodbav:"version"`
+}
+
+func active() *expression.ConditionBuilder {
+	c := expression.Name("status").Equal(expression.Value("active"))
+	return &c
+}
+
+// writeCondition returns the condition of the last write request, with the attribute names in place of their placeholders.
+func writeCondition(t *testing.T, fake *fakeDynamoDB) string {
+	t.Helper()
+	requests := fake.Requests("")
+	r := requests[len(requests)-1]
+	var condition *string
+	var names map[string]*string
+	switch r.Operation {
+	case "PutItem":
+		var input dynamodb.PutItemInput
+		r.Decode(t, &input)
+		condition, names = input.ConditionExpression, input.ExpressionAttributeNames
+	case "UpdateItem":
+		var input dynamodb.UpdateItemInput
+		r.Decode(t, &input)
+		condition, names = input.ConditionExpression, input.ExpressionAttributeNames
+	case "DeleteItem":
+		var input dynamodb.DeleteItemInput
+		r.Decode(t, &input)
+		condition, names = input.ConditionExpression, input.ExpressionAttributeNames
+	default:
+		t.Fatalf("unexpected %s", r.Operation)
+	}
+	s := aws.StringValue(condition)
+	for placeholder, name := range names {
+		s = replaceName(s, placeholder, aws.StringValue(name))
+	}
+	return s
+}
+
+// expand replaces the placeholders of an expression by the names and the values, a value is written as its string, number or binary.
+func expand(s string, names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+	for placeholder, name := range names {
+		s = replaceName(s, placeholder, aws.StringValue(name))
+	}
+	for placeholder, v := range values {
+		value := v.String()
+		switch {
+		case v.S != nil:
+			value = *v.S
+		case v.N != nil:
+			value = *v.N
+		case v.B != nil:
+			value = string(v.B)
+		}
+		s = replaceName(s, placeholder, value)
+	}
+	return s
+}
+
+// replaceName replaces the placeholder, but not a longer placeholder which starts with it, such as #1 in #10.
+func replaceName(s string, placeholder string, name string) string {
+	var result []byte
+	for i := 0; i < len(s); {
+		if i+len(placeholder) <= len(s) && s[i:i+len(placeholder)] == placeholder && (i+len(placeholder) == len(s) || s[i+len(placeholder)] < '0' || s[i+len(placeholder)] > '9') {
+			result = append(result, name...)
+			i += len(placeholder)
+		} else {
+			result = append(result, s[i])
+			i++
+		}
+	}
+	return string(result)
+}
+
+func TestWriteCondition(t *testing.T) {
+	keys := []string{"id"}
+	a := account{Id: "a1", Status: "active", Version: 2}
+	tests := []struct {
+		name      string
+		write     func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error)
+		condition string
+	}{
+		{"insert", func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return InsertOne(ctx, db, "accounts", keys, a, options)
+		}, "(attribute_not_exists (id)) AND (status = :0)"},
+		{"update", func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOne(ctx, db, "accounts", keys, a, options)
+		}, "(attribute_exists (id)) AND (status = :0)"},
+		{"update with version", func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOneWithVersion(ctx, db, "accounts", keys, &a, 2, "version", options)
+		}, "((attribute_exists (id)) AND (version = :0)) AND (status = :1)"},
+		{"upsert", func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpsertOne(ctx, db, "accounts", keys, a, options)
+		}, "status = :0"},
+		{"patch", func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return PatchOne(ctx, db, "accounts", keys, map[string]interface{}{"id": "a1", "status": "closed"}, options)
+		}, "(attribute_exists (id)) AND (status = :0)"},
+		{"delete", func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return DeleteOne(ctx, db, "accounts", keys, "a1", options)
+		}, "(attribute_exists (id)) AND (status = :0)"},
+		{"update with expression", func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateWithExpression(ctx, db, "accounts", keys, "a1", expression.Set(expression.Name("status"), expression.Value("closed")), options)
+		}, "(attribute_exists (id)) AND (status = :0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, nil)
+			if _, err := tt.write(context.Background(), db, WriteOptions{Condition: active()}); err != nil {
+				t.Fatal(err)
+			}
+			if condition := writeCondition(t, fake); condition != tt.condition {
+				t.Errorf("condition = %q, want %q", condition, tt.condition)
+			}
+		})
+	}
+}
+
+func TestWriteConditionFailed(t *testing.T) {
+	keys := []string{"id"}
+	stored := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a1")}, "status": {S: aws.String("closed")}, "version": {N: aws.String("3")}}
+	tests := []struct {
+		name    string
+		stored  map[string]*dynamodb.AttributeValue
+		write   func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error)
+		message string
+	}{
+		{"insert over an existing item", stored, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return InsertOne(ctx, db, "accounts", keys, account{Id: "a1"}, options)
+		}, ObjectExist},
+		{"insert with a false condition", nil, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return InsertOne(ctx, db, "accounts", keys, account{Id: "a1"}, options)
+		}, ConditionCheckFailed},
+		{"update of a missing item", nil, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOne(ctx, db, "accounts", keys, account{Id: "a1"}, options)
+		}, ObjectNotFound},
+		{"update with a false condition", stored, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOne(ctx, db, "accounts", keys, account{Id: "a1"}, options)
+		}, ConditionCheckFailed},
+		{"update with a wrong version", stored, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOneWithVersion(ctx, db, "accounts", keys, &account{Id: "a1", Version: 2}, 2, "version", options)
+		}, WrongVersion},
+		{"delete of a missing item", nil, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return DeleteOne(ctx, db, "accounts", keys, "a1", options)
+		}, ObjectNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				return fakeError{Code: dynamodb.ErrCodeConditionalCheckFailedException, Item: tt.stored}
+			})
+			_, err := tt.write(context.Background(), db, WriteOptions{Condition: active(), ReturnValuesOnConditionCheckFailure: true})
+			e, ok := err.(*ConditionalCheckFailedError)
+			if !ok {
+				t.Fatalf("err = %v, want a ConditionalCheckFailedError", err)
+			}
+			if e.Message != tt.message {
+				t.Errorf("message = %q, want %q", e.Message, tt.message)
+			}
+			var a account
+			if found, _ := e.Decode(&a); found != (tt.stored != nil) || (found && a.Status != "closed") {
+				t.Errorf("Decode = %v, %v, want the stored item", found, a)
+			}
+		})
+	}
+}
+
+func TestReturnValues(t *testing.T) {
+	keys := []string{"id"}
+	old := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a1")}, "status": {S: aws.String("old")}}
+	tests := []struct {
+		name         string
+		returnValues string
+		write        func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error)
+		sent         string
+		status       string
+		err          bool
+	}{
+		{"put returns the old item", dynamodb.ReturnValueAllOld, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOne(ctx, db, "accounts", keys, account{Id: "a1", Status: "new"}, options)
+		}, dynamodb.ReturnValueAllOld, "old", false},
+		{"put returns the old item for updated old", dynamodb.ReturnValueUpdatedOld, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOne(ctx, db, "accounts", keys, account{Id: "a1", Status: "new"}, options)
+		}, dynamodb.ReturnValueAllOld, "old", false},
+		{"put returns the written item as new image", dynamodb.ReturnValueAllNew, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return UpdateOne(ctx, db, "accounts", keys, account{Id: "a1", Status: "new"}, options)
+		}, "", "new", false},
+		{"update returns the requested image", dynamodb.ReturnValueUpdatedOld, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return PatchOne(ctx, db, "accounts", keys, map[string]interface{}{"id": "a1", "status": "new"}, options)
+		}, dynamodb.ReturnValueUpdatedOld, "old", false},
+		{"delete returns the old item", dynamodb.ReturnValueAllOld, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return DeleteOne(ctx, db, "accounts", keys, "a1", options)
+		}, dynamodb.ReturnValueAllOld, "old", false},
+		{"delete has no new image", dynamodb.ReturnValueAllNew, func(ctx context.Context, db *dynamodb.DynamoDB, options WriteOptions) (int64, error) {
+			return DeleteOne(ctx, db, "accounts", keys, "a1", options)
+		}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent *string
+			db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				switch r.Operation {
+				case "PutItem":
+					var input dynamodb.PutItemInput
+					r.Decode(t, &input)
+					sent = input.ReturnValues
+					if input.ReturnValues != nil {
+						return &dynamodb.PutItemOutput{Attributes: old}
+					}
+				case "UpdateItem":
+					var input dynamodb.UpdateItemInput
+					r.Decode(t, &input)
+					sent = input.ReturnValues
+					return &dynamodb.UpdateItemOutput{Attributes: old}
+				case "DeleteItem":
+					var input dynamodb.DeleteItemInput
+					r.Decode(t, &input)
+					sent = input.ReturnValues
+					return &dynamodb.DeleteItemOutput{Attributes: old}
+				}
+				return nil
+			})
+			var result account
+			_, err := tt.write(context.Background(), db, WriteOptions{ReturnValues: tt.returnValues, Result: &result})
+			if tt.err {
+				if err == nil || len(fake.Requests("")) > 0 {
+					t.Errorf("err = %v after %d requests, want an error without request", err, len(fake.Requests("")))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.StringValue(sent) != tt.sent {
+				t.Errorf("ReturnValues = %q, want %q", aws.StringValue(sent), tt.sent)
+			}
+			if result.Status != tt.status {
+				t.Errorf("result status = %q, want %q", result.Status, tt.status)
+			}
+		})
+	}
+}
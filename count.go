@@ -0,0 +1,253 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CountNone     = "none"
+	CountExact    = "exact"
+	CountEstimate = "estimate"
+)
+
+var namePlaceholder = regexp.MustCompile(`#[0-9A-Za-z_]+`)
+
+// countNames returns the attribute names which are still used without the projection, because a COUNT cannot have a projection.
+func countNames(names map[string]*string, expressions ...*string) map[string]*string {
+	if len(names) == 0 {
+		return names
+	}
+	used := make(map[string]*string)
+	for _, expr := range expressions {
+		for _, name := range namePlaceholder.FindAllString(aws.StringValue(expr), -1) {
+			if v, ok := names[name]; ok {
+				used[name] = v
+			}
+		}
+	}
+	if len(used) == 0 {
+		return nil
+	}
+	return used
+}
+
+// CountScan counts the items of the scan across all pages, with segments parallel scans if segments > 1.
+// The first error of a segment cancels the other segments and is returned.
+func CountScan(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.ScanInput, segments int) (int64, error) {
+	query.Select = aws.String(dynamodb.SelectCount)
+	query.ExpressionAttributeNames = countNames(query.ExpressionAttributeNames, query.FilterExpression)
+	query.ProjectionExpression = nil
+	query.Limit = nil
+	query.ExclusiveStartKey = nil
+	if segments <= 1 {
+		return countScan(ctx, db, query)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var once sync.Once
+	var total int64
+	var err error
+	for i := 0; i < segments; i++ {
+		segment := query
+		segment.Segment = aws.Int64(int64(i))
+		segment.TotalSegments = aws.Int64(int64(segments))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, er1 := countScan(ctx, db, segment)
+			if er1 != nil {
+				once.Do(func() {
+					err = er1
+					cancel()
+				})
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			total += count
+		}()
+	}
+	wg.Wait()
+	return total, err
+}
+
+func countScan(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.ScanInput) (int64, error) {
+	var count int64
+	err := db.ScanPagesWithContext(ctx, &query, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		count += aws.Int64Value(page.Count)
+		return true
+	})
+	return count, err
+}
+
+// CountQuery counts the items of the query across all pages, a QueryInput without key condition is counted as a Scan.
+// With boundaries, the Query reads the attributes of the boundaries instead of a COUNT, to skip the items on them.
+func CountQuery(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.QueryInput, segments int, boundaries ...Boundary) (int64, error) {
+	if query.KeyConditionExpression == nil {
+		return CountScan(ctx, db, ToScanInput(&query), segments)
+	}
+	query.Select = aws.String(dynamodb.SelectCount)
+	query.ExpressionAttributeNames = countNames(query.ExpressionAttributeNames, query.KeyConditionExpression, query.FilterExpression)
+	query.ProjectionExpression = nil
+	query.Limit = nil
+	query.ExclusiveStartKey = nil
+	if len(boundaries) > 0 {
+		projectBoundaries(&query, boundaries)
+	}
+	var count int64
+	err := db.QueryPagesWithContext(ctx, &query, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		if len(boundaries) > 0 {
+			count += int64(len(ExcludeBoundaries(page.Items, boundaries)))
+		} else {
+			count += aws.Int64Value(page.Count)
+		}
+		return true
+	})
+	return count, err
+}
+
+func projectBoundaries(query *dynamodb.QueryInput, boundaries []Boundary) {
+	names := make(map[string]*string)
+	for k, v := range query.ExpressionAttributeNames {
+		names[k] = v
+	}
+	var projection []string
+	for i, b := range boundaries {
+		placeholder := "#boundary" + strconv.Itoa(i)
+		names[placeholder] = aws.String(b.Name)
+		projection = append(projection, placeholder)
+	}
+	query.Select = aws.String(dynamodb.SelectSpecificAttributes)
+	query.ProjectionExpression = aws.String(strings.Join(projection, ", "))
+	query.ExpressionAttributeNames = names
+}
+
+// EstimateCount returns the ItemCount of the table or of the index, which DynamoDB updates about every six hours.
+func EstimateCount(ctx context.Context, db *dynamodb.DynamoDB, tableName string, indexName string) (int64, error) {
+	output, err := db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return 0, err
+	}
+	for _, index := range output.Table.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == indexName {
+			return aws.Int64Value(index.ItemCount), nil
+		}
+	}
+	for _, index := range output.Table.LocalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == indexName {
+			return aws.Int64Value(index.ItemCount), nil
+		}
+	}
+	return aws.Int64Value(output.Table.ItemCount), nil
+}
+
+type countEntry struct {
+	count     int64
+	expiredAt time.Time
+}
+
+// CountCache keeps the counts of the searches for a short TTL, its zero value is a cache whose entries expire at once.
+type CountCache struct {
+	TTL     time.Duration
+	Now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]countEntry
+}
+
+func NewCountCache(ttl time.Duration) *CountCache {
+	return &CountCache{TTL: ttl, entries: make(map[string]countEntry)}
+}
+
+func (c *CountCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *CountCache) Get(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]countEntry)
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+	if !c.now().Before(entry.expiredAt) {
+		delete(c.entries, key)
+		return 0, false
+	}
+	return entry.count, true
+}
+
+func (c *CountCache) Set(key string, count int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]countEntry)
+	}
+	now := c.now()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expiredAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = countEntry{count: count, expiredAt: now.Add(c.TTL)}
+}
+
+// CountKey is the cache key of the count of a query, it does not depend on the page or the sort of the search.
+func CountKey(query dynamodb.QueryInput) string {
+	key, _ := json.Marshal(struct {
+		TableName *string
+		IndexName *string
+		Key       *string
+		Filter    *string
+		Names     map[string]*string
+		Values    map[string]*dynamodb.AttributeValue
+	}{query.TableName, query.IndexName, query.KeyConditionExpression, query.FilterExpression, query.ExpressionAttributeNames, query.ExpressionAttributeValues})
+	return string(key)
+}
+
+// Count counts the items of the query in the mode CountExact or CountEstimate, it returns -1 with CountNone.
+// An exact count skips the items on the boundaries.
+func Count(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.QueryInput, mode string, segments int, cache *CountCache, boundaries ...Boundary) (int64, error) {
+	if mode != CountExact && mode != CountEstimate {
+		return -1, nil
+	}
+	key := mode + CountKey(query)
+	if len(boundaries) > 0 {
+		data, _ := json.Marshal(boundaries)
+		key += string(data)
+	}
+	if cache != nil {
+		if count, ok := cache.Get(key); ok {
+			return count, nil
+		}
+	}
+	var count int64
+	var err error
+	if mode == CountExact {
+		count, err = CountQuery(ctx, db, query, segments, boundaries...)
+	} else {
+		count, err = EstimateCount(ctx, db, aws.StringValue(query.TableName), aws.StringValue(query.IndexName))
+	}
+	if err != nil {
+		return 0, err
+	}
+	if cache != nil {
+		cache.Set(key, count)
+	}
+	return count, nil
+}
@@ -0,0 +1,148 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"testing"
+	"time"
+)
+
+func TestCount(t *testing.T) {
+	query := dynamodb.QueryInput{
+		TableName:                 aws.String("orders"),
+		IndexName:                 aws.String("byStatus"),
+		KeyConditionExpression:    aws.String("#0 = :0"),
+		ProjectionExpression:      aws.String("#1"),
+		ExpressionAttributeNames:  map[string]*string{"#0": aws.String("status"), "#1": aws.String("amount")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":0": {S: aws.String("paid")}},
+		Limit:                     aws.Int64(10),
+	}
+	tests := []struct {
+		name  string
+		mode  string
+		count int64
+		op    string
+	}{
+		{"exact", CountExact, 7, "Query"},
+		{"estimate", CountEstimate, 42, "DescribeTable"},
+		{"none", CountNone, -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				switch r.Operation {
+				case "Query":
+					var input dynamodb.QueryInput
+					r.Decode(t, &input)
+					if input.ExclusiveStartKey == nil {
+						return &dynamodb.QueryOutput{Count: aws.Int64(4), LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o4")}}}
+					}
+					return &dynamodb.QueryOutput{Count: aws.Int64(3)}
+				case "DescribeTable":
+					return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+						ItemCount:              aws.Int64(100),
+						GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{{IndexName: aws.String("byStatus"), ItemCount: aws.Int64(42)}},
+					}}
+				}
+				return nil
+			})
+			count, err := Count(context.Background(), db, query, tt.mode, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.count {
+				t.Errorf("count = %d, want %d", count, tt.count)
+			}
+			requests := fake.Requests("")
+			if len(tt.op) == 0 {
+				if len(requests) != 0 {
+					t.Errorf("%d requests, want none", len(requests))
+				}
+				return
+			}
+			if requests[0].Operation != tt.op {
+				t.Fatalf("operation = %s, want %s", requests[0].Operation, tt.op)
+			}
+			if tt.op == "Query" {
+				var input dynamodb.QueryInput
+				requests[0].Decode(t, &input)
+				if aws.StringValue(input.Select) != dynamodb.SelectCount || input.ProjectionExpression != nil || input.Limit != nil {
+					t.Errorf("query = %v, want a COUNT of all items", input)
+				}
+				if _, ok := input.ExpressionAttributeNames["#1"]; ok {
+					t.Errorf("names = %v, want the names of the projection removed", input.ExpressionAttributeNames)
+				}
+			}
+		})
+	}
+}
+
+func TestCountCache(t *testing.T) {
+	query := dynamodb.QueryInput{TableName: aws.String("orders"), KeyConditionExpression: aws.String("#0 = :0"), ExpressionAttributeNames: map[string]*string{"#0": aws.String("status")}, ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":0": {S: aws.String("paid")}}}
+	db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		return &dynamodb.QueryOutput{Count: aws.Int64(5)}
+	})
+	now := time.Unix(1700000000, 0)
+	cache := NewCountCache(time.Minute)
+	cache.Now = func() time.Time {
+		return now
+	}
+	for _, after := range []time.Duration{0, 30 * time.Second, time.Minute} {
+		now = time.Unix(1700000000, 0).Add(after)
+		if count, err := Count(context.Background(), db, query, CountExact, 0, cache); err != nil || count != 5 {
+			t.Fatalf("Count = %d, %v, want 5", count, err)
+		}
+	}
+	if queries := len(fake.Requests("Query")); queries != 2 {
+		t.Errorf("%d queries, want the count cached until it expires", queries)
+	}
+	var zero CountCache
+	zero.Set("k", 1)
+	if _, ok := zero.Get("k"); ok {
+		t.Error("the count of a cache without TTL is kept")
+	}
+}
+
+func TestCountKey(t *testing.T) {
+	query := dynamodb.QueryInput{TableName: aws.String("orders"), KeyConditionExpression: aws.String("#0 = :0"), ExpressionAttributeNames: map[string]*string{"#0": aws.String("status")}, ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":0": {S: aws.String("paid")}}}
+	page := query
+	page.Limit = aws.Int64(20)
+	page.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o1")}}
+	page.ScanIndexForward = aws.Bool(false)
+	if CountKey(page) != CountKey(query) {
+		t.Error("the key depends on the page or the sort")
+	}
+	other := query
+	other.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":0": {S: aws.String("new")}}
+	if CountKey(other) == CountKey(query) {
+		t.Error("the key does not depend on the values")
+	}
+}
+
+func TestCountScanCancelsSegments(t *testing.T) {
+	db, _ := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		var input dynamodb.ScanInput
+		r.Decode(t, &input)
+		if aws.Int64Value(input.Segment) == 0 {
+			return fakeError{Code: "ValidationException"}
+		}
+		// The other segments never end unless they are canceled.
+		time.Sleep(time.Millisecond)
+		return &dynamodb.ScanOutput{Count: aws.Int64(1), LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o1")}}}
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := CountScan(context.Background(), db, dynamodb.ScanInput{TableName: aws.String("orders")}, 4)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if e, ok := err.(awserr.Error); !ok || e.Code() != "ValidationException" {
+			t.Errorf("err = %v, want the error of the segment", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the other segments are not canceled")
+	}
+}
@@ -0,0 +1,201 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"time"
+)
+
+// Cursor iterates the items of a Query, or of a Scan if there is no key condition or if it is created by NewScanCursor, reading the pages when they are needed.
+//
+//	for cursor.Next() {
+//		u, ok := cursor.Item().(*User)
+//		if !ok {
+//			return cursor.Err()
+//		}
+//		// use u
+//	}
+//	if err := cursor.Err(); err != nil {
+//		return err
+//	}
+type Cursor struct {
+	Database  *dynamodb.DynamoDB
+	ModelType reflect.Type
+	Map       func(ctx context.Context, model interface{}) (interface{}, error)
+	// Keys are the key attributes of the table and of the index, to build the checkpoint in the middle of the last page.
+	Keys             []string
+	ctx              context.Context
+	query            dynamodb.QueryInput
+	scan             *dynamodb.ScanInput
+	items            []map[string]*dynamodb.AttributeValue
+	index            int
+	lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	started          bool
+	item             interface{}
+	err              error
+}
+
+// NewCursor creates a cursor from the ExclusiveStartKey of the query, which can be a checkpoint of another cursor.
+func NewCursor(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.QueryInput, modelType reflect.Type, options ...func(context.Context, interface{}) (interface{}, error)) *Cursor {
+	var mp func(context.Context, interface{}) (interface{}, error)
+	if len(options) > 0 && options[0] != nil {
+		mp = options[0]
+	}
+	return &Cursor{Database: db, ModelType: modelType, Map: mp, ctx: ctx, query: query, index: -1}
+}
+
+func NewScanCursor(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.ScanInput, modelType reflect.Type, options ...func(context.Context, interface{}) (interface{}, error)) *Cursor {
+	cursor := NewCursor(ctx, db, dynamodb.QueryInput{}, modelType, options...)
+	cursor.scan = &query
+	return cursor
+}
+
+// Next moves to the next item, it returns false at the end or on error.
+func (c *Cursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+	c.item = nil
+	c.index++
+	for c.index >= len(c.items) {
+		if c.started && len(c.lastEvaluatedKey) == 0 {
+			c.index = len(c.items)
+			return false
+		}
+		if c.started {
+			c.setStartKey(c.lastEvaluatedKey)
+		}
+		c.started = true
+		if c.err = c.read(); c.err != nil {
+			return false
+		}
+		c.index = 0
+	}
+	return true
+}
+
+func (c *Cursor) setStartKey(key map[string]*dynamodb.AttributeValue) {
+	if c.scan != nil {
+		c.scan.ExclusiveStartKey = key
+	} else {
+		c.query.ExclusiveStartKey = key
+	}
+}
+
+func (c *Cursor) read() error {
+	if c.scan != nil || c.query.KeyConditionExpression == nil {
+		scan := c.scan
+		if scan == nil {
+			s := ToScanInput(&c.query)
+			scan = &s
+		}
+		output, err := c.Database.ScanWithContext(c.ctx, scan)
+		if err != nil {
+			return err
+		}
+		c.items, c.lastEvaluatedKey = output.Items, output.LastEvaluatedKey
+		return nil
+	}
+	output, err := c.Database.QueryWithContext(c.ctx, &c.query)
+	if err != nil {
+		return err
+	}
+	c.items, c.lastEvaluatedKey = output.Items, output.LastEvaluatedKey
+	return nil
+}
+
+// Item decodes the current item into a pointer to the model type and applies Map; it returns nil if decoding fails, see Err.
+func (c *Cursor) Item() interface{} {
+	if c.item != nil || c.err != nil || c.index < 0 || c.index >= len(c.items) {
+		return c.item
+	}
+	model := reflect.New(c.ModelType).Interface()
+	if c.err = dynamodbattribute.UnmarshalMap(c.items[c.index], model); c.err != nil {
+		return nil
+	}
+	if c.Map != nil {
+		if _, c.err = c.Map(c.ctx, model); c.err != nil {
+			return nil
+		}
+	}
+	c.item = model
+	return model
+}
+
+// Decode decodes the current item into result, without Map.
+func (c *Cursor) Decode(result interface{}) error {
+	if c.index < 0 || c.index >= len(c.items) {
+		return nil
+	}
+	return dynamodbattribute.UnmarshalMap(c.items[c.index], result)
+}
+
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// LastEvaluatedKey is the LastEvaluatedKey of the current page, it is nil on the last page.
+func (c *Cursor) LastEvaluatedKey() map[string]*dynamodb.AttributeValue {
+	return c.lastEvaluatedKey
+}
+
+// Checkpoint returns the start key to resume after the current item, or nil if there are no more items.
+// It is the LastEvaluatedKey at the end of a page, otherwise the key of the current item.
+func (c *Cursor) Checkpoint() (map[string]*dynamodb.AttributeValue, error) {
+	if !c.started {
+		if c.scan != nil {
+			return c.scan.ExclusiveStartKey, nil
+		}
+		return c.query.ExclusiveStartKey, nil
+	}
+	if c.index < 0 || c.index >= len(c.items)-1 {
+		return c.lastEvaluatedKey, nil
+	}
+	names := c.Keys
+	if len(names) == 0 {
+		for name := range c.lastEvaluatedKey {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, ErrUnknownStartKey
+	}
+	key := make(map[string]*dynamodb.AttributeValue)
+	for _, name := range names {
+		if v, ok := c.items[c.index][name]; ok {
+			key[name] = v
+		}
+	}
+	return key, nil
+}
+
+// Iterate returns a cursor on the query, which applies the Map of the loader.
+// The key attributes of an index are read from the table description, to build the checkpoint in the middle of the last page.
+func (m *Loader) Iterate(ctx context.Context, query dynamodb.QueryInput) (*Cursor, error) {
+	cursor := NewCursor(ctx, m.Database, query, m.modelType, m.Map)
+	if query.IndexName == nil {
+		cursor.Keys = m.Keys()
+		return cursor, nil
+	}
+	keys, err := GetKeyNames(ctx, m.Database, m.tableName, *query.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	cursor.Keys = keys
+	return cursor, nil
+}
+
+// IterateAll returns a cursor on all items of the table, without the deleted and expired items.
+func (m *Loader) IterateAll(ctx context.Context) (*Cursor, error) {
+	query, err := BuildQuery(m.tableName, SecondaryIndex{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	ExcludeDeleted(query, m.SoftDelete)
+	ExcludeExpired(query, m.TTLName, time.Now())
+	cursor := NewScanCursor(ctx, m.Database, *query, m.modelType, m.Map)
+	cursor.Keys = m.Keys()
+	return cursor, nil
+}
@@ -0,0 +1,103 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"reflect"
+	"testing"
+)
+
+func order(customerId string, id string, status string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id":         {S: aws.String(id)},
+		"customerId": {S: aws.String(customerId)},
+		"status":     {S: aws.String(status)},
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	items := []map[string]*dynamodb.AttributeValue{order("c1", "o1", "new"), order("c1", "o2", "paid"), order("c2", "o3", "new")}
+	lastKey := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o3")}, "customerId": {S: aws.String("c2")}}
+	tests := []struct {
+		name             string
+		keys             []string
+		index            int
+		lastEvaluatedKey map[string]*dynamodb.AttributeValue
+		checkpoint       map[string]*dynamodb.AttributeValue
+		err              error
+	}{
+		{"end of a page", nil, 2, lastKey, lastKey, nil},
+		{"end of the last page", nil, 2, nil, nil, nil},
+		{"middle of a page", nil, 0, lastKey, map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o1")}, "customerId": {S: aws.String("c1")}}, nil},
+		{"middle of the last page with the keys", []string{"id", "customerId"}, 1, nil, map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o2")}, "customerId": {S: aws.String("c1")}}, nil},
+		{"middle of the last page without the keys", nil, 1, nil, nil, ErrUnknownStartKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cursor{Keys: tt.keys, started: true, items: items, index: tt.index, lastEvaluatedKey: tt.lastEvaluatedKey}
+			checkpoint, err := c.Checkpoint()
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(checkpoint, tt.checkpoint) {
+				t.Errorf("checkpoint = %v, want %v", checkpoint, tt.checkpoint)
+			}
+		})
+	}
+}
+
+func TestNewScanCursor(t *testing.T) {
+	startKey := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o1")}}
+	scan := dynamodb.ScanInput{
+		TableName:              aws.String("orders"),
+		Segment:                aws.Int64(1),
+		TotalSegments:          aws.Int64(4),
+		ScanFilter:             map[string]*dynamodb.Condition{"status": {ComparisonOperator: aws.String(dynamodb.ComparisonOperatorEq)}},
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		ExclusiveStartKey:      startKey,
+	}
+	c := NewScanCursor(context.Background(), nil, scan, reflect.TypeOf(struct{}{}))
+	if c.scan == nil || !reflect.DeepEqual(*c.scan, scan) {
+		t.Errorf("scan = %v, want %v", c.scan, scan)
+	}
+	checkpoint, err := c.Checkpoint()
+	if err != nil || !reflect.DeepEqual(checkpoint, startKey) {
+		t.Errorf("checkpoint = %v, %v before reading, want %v", checkpoint, err, startKey)
+	}
+	c.started = true
+	c.setStartKey(nil)
+	if c.scan.ExclusiveStartKey != nil || c.query.ExclusiveStartKey != nil {
+		t.Error("the start key of the next page is not set on the scan")
+	}
+}
+
+func TestKeyNames(t *testing.T) {
+	key := func(name string, keyType string) *dynamodb.KeySchemaElement {
+		return &dynamodb.KeySchemaElement{AttributeName: aws.String(name), KeyType: aws.String(keyType)}
+	}
+	table := &dynamodb.TableDescription{
+		KeySchema: []*dynamodb.KeySchemaElement{key("id", dynamodb.KeyTypeHash)},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{{
+			IndexName: aws.String("byCustomer"),
+			KeySchema: []*dynamodb.KeySchemaElement{key("createdAt", dynamodb.KeyTypeRange), key("customerId", dynamodb.KeyTypeHash)},
+		}, {
+			IndexName: aws.String("byId"),
+			KeySchema: []*dynamodb.KeySchemaElement{key("id", dynamodb.KeyTypeHash), key("status", dynamodb.KeyTypeRange)},
+		}},
+	}
+	tests := []struct {
+		indexName string
+		keys      []string
+	}{
+		{"", []string{"id"}},
+		{"byCustomer", []string{"id", "customerId", "createdAt"}},
+		{"byId", []string{"id", "status"}},
+		{"unknown", []string{"id"}},
+	}
+	for _, tt := range tests {
+		if keys := keyNamesOf(table, tt.indexName); !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("keyNamesOf(%q) = %v, want %v", tt.indexName, keys, tt.keys)
+		}
+	}
+}
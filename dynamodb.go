@@ -9,9 +9,11 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"math/rand"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -20,10 +22,19 @@ const (
 	EQUAL   = "equal"
 )
 
+// BatchGetAttempts is the number of BatchGetItem calls for a page of GetItems, the unprocessed keys of a throttled table are retried with a jittered exponential backoff.
+const BatchGetAttempts = 8
+
+// batchGetBackoff is the first wait before retrying the unprocessed keys, it doubles at each attempt.
+var batchGetBackoff = 50 * time.Millisecond
+
 type (
 	SecondaryIndex struct {
 		IndexName string
 		Keys      []string
+		// Projection is the projection type of the index, empty is ALL.
+		Projection       string
+		NonKeyAttributes []string
 	}
 	Config struct {
 		Region          string `mapstructure:"region" json:"region,omitempty" gorm:"column:region" bson:"region,omitempty" dynamodbav:"region,omitempty" firestore:"region,omitempty"`
@@ -104,22 +115,27 @@ func BuildQuery(tableName string, index SecondaryIndex, query map[string]interfa
 }
 
 func Exist(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}) (bool, error) {
-	keyMap, err := buildKeyMap(keys, id)
+	item, err := GetItem(ctx, db, tableName, keys, id)
 	if err != nil {
 		return false, err
 	}
+	return len(item) > 0, nil
+}
+
+func GetItem(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}) (map[string]*dynamodb.AttributeValue, error) {
+	keyMap, err := buildKeyMap(keys, id)
+	if err != nil {
+		return nil, err
+	}
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
 		Key:       keyMap,
 	}
 	resp, err := db.GetItemWithContext(ctx, input)
 	if err != nil {
-		return false, err
-	}
-	if len(resp.Item) == 0 {
-		return false, nil
+		return nil, err
 	}
-	return true, nil
+	return resp.Item, nil
 }
 
 func Find(ctx context.Context, db *dynamodb.DynamoDB, query *dynamodb.ScanInput, modelType reflect.Type) (interface{}, error) {
@@ -129,15 +145,20 @@ func Find(ctx context.Context, db *dynamodb.DynamoDB, query *dynamodb.ScanInput,
 	return result, err
 }
 
+// FindAndDecode decodes the items of all pages of the scan.
 func FindAndDecode(ctx context.Context, db *dynamodb.DynamoDB, query *dynamodb.ScanInput, result interface{}) (bool, error) {
-	output, err := db.ScanWithContext(ctx, query)
+	var items []map[string]*dynamodb.AttributeValue
+	err := db.ScanPagesWithContext(ctx, query, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
 	if err != nil {
 		return false, err
 	}
-	if len(output.Items) == 0 {
+	if len(items) == 0 {
 		return false, nil
 	}
-	err = dynamodbattribute.UnmarshalListOfMaps(output.Items, result)
+	err = dynamodbattribute.UnmarshalListOfMaps(items, result)
 	return true, err
 }
 
@@ -185,6 +206,58 @@ func FindByIdsAndDecode(ctx context.Context, db *dynamodb.DynamoDB, tableName st
 	return true, unprocessedKeys, nil
 }
 
+// GetItems reads the items of the ids with BatchGetItem, by pages of 100 keys, retrying the unprocessed keys at most BatchGetAttempts times per page.
+func GetItems(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, ids interface{}) ([]map[string]*dynamodb.AttributeValue, error) {
+	values := reflect.Indirect(reflect.ValueOf(ids))
+	if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+		return nil, fmt.Errorf("ids must be a slice")
+	}
+	var items []map[string]*dynamodb.AttributeValue
+	for start := 0; start < values.Len(); start += 100 {
+		end := start + 100
+		if end > values.Len() {
+			end = values.Len()
+		}
+		var keyMaps []map[string]*dynamodb.AttributeValue
+		for i := start; i < end; i++ {
+			keyMap, err := buildKeyMap(keys, values.Index(i).Interface())
+			if err != nil {
+				return items, err
+			}
+			keyMaps = append(keyMaps, keyMap)
+		}
+		request := map[string]*dynamodb.KeysAndAttributes{tableName: {Keys: keyMaps}}
+		backoff := batchGetBackoff
+		for attempt := 1; len(request) > 0; attempt++ {
+			if attempt > 1 {
+				if attempt > BatchGetAttempts {
+					return items, fmt.Errorf("%d keys are still unprocessed after %d attempts", len(request[tableName].Keys), BatchGetAttempts)
+				}
+				if err := sleep(ctx, backoff/2+time.Duration(rand.Int63n(int64(backoff/2)+1))); err != nil {
+					return items, err
+				}
+				backoff *= 2
+			}
+			resp, err := db.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+			if err != nil {
+				return items, err
+			}
+			items = append(items, resp.Responses[tableName]...)
+			request = resp.UnprocessedKeys
+		}
+	}
+	return items, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func FindOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, keys []string, id interface{}) (interface{}, error) {
 	result := reflect.New(modelType).Interface()
 	if ok, err := FindOneAndDecode(ctx, db, tableName, keys, id, result); ok {
@@ -195,22 +268,14 @@ func FindOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, model
 }
 
 func FindOneAndDecode(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, result interface{}) (bool, error) {
-	keyMap, err := buildKeyMap(keys, id)
+	item, err := GetItem(ctx, db, tableName, keys, id)
 	if err != nil {
 		return false, err
 	}
-	input := &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key:       keyMap,
-	}
-	resp, err := db.GetItemWithContext(ctx, input)
-	if err != nil {
-		return false, err
-	}
-	if len(resp.Item) == 0 {
+	if len(item) == 0 {
 		return false, fmt.Errorf("item not found")
 	}
-	err = dynamodbattribute.UnmarshalMap(resp.Item, result)
+	err = dynamodbattribute.UnmarshalMap(item, result)
 	return true, err
 }
 
@@ -235,36 +300,16 @@ func FindOneAndReturnMapData(ctx context.Context, db *dynamodb.DynamoDB, tableNa
 	return true, result, err
 }
 
-func InsertOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}) (int64, error) {
-	var strWhere string
-	if len(keys) > 0 {
-		strWhere = fmt.Sprintf("attribute_not_exists(%s)", strings.Join(keys, ","))
-	}
+func InsertOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, options ...WriteOptions) (int64, error) {
 	modelMap, err := dynamodbattribute.MarshalMap(model)
 	if err != nil {
 		return 0, err
 	}
-	params := &dynamodb.PutItemInput{
-		TableName:              aws.String(tableName),
-		Item:                   modelMap,
-		ConditionExpression: 	aws.String(strWhere),
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-	output, err := db.PutItemWithContext(ctx, params)
-	if err != nil {
-		if strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0 {
-			return 0, fmt.Errorf("object exist")
-		}
-		return 0, err
-	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+	opts := getWriteOptions(options)
+	return putItem(ctx, db, tableName, modelMap, And(notExistsCondition(keys, opts), opts.Condition), insertFailed, opts)
 }
 
-func InsertOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, versionIndex int, versionField string) (int64, error) {
-	var strWhere string
-	if len(keys) > 0 {
-		strWhere = fmt.Sprintf("attribute_not_exists(%s)", strings.Join(keys, ","))
-	}
+func InsertOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, versionIndex int, versionField string, options ...WriteOptions) (int64, error) {
 	modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
 	versionType := modelType.Field(versionIndex).Type.String()
 	if ok := strings.Contains(versionType, "int"); !ok {
@@ -275,240 +320,183 @@ func InsertOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName
 		return 0, err
 	}
 	modelMap[versionField] = &dynamodb.AttributeValue{N: aws.String("1")}
-	params := &dynamodb.PutItemInput{
-		TableName:              aws.String(tableName),
-		Item:                   modelMap,
-		ConditionExpression: 	aws.String(strWhere),
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-	output, err := db.PutItemWithContext(ctx, params)
-	if err != nil {
-		if strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0 {
-			return 0, fmt.Errorf("object exist")
-		}
-		return 0, err
-	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+	opts := getWriteOptions(options)
+	return putItem(ctx, db, tableName, modelMap, And(notExistsCondition(keys, opts), opts.Condition), insertFailed, opts)
 }
 
-func UpdateOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}) (int64, error) {
+func UpdateOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, options ...WriteOptions) (int64, error) {
 	ids := getIdValueFromModel(model, keys)
-	expected, err := buildKeyMapWithExpected(keys, ids, true)
-	if err != nil {
-		return 0, err
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("cannot update one an Object that do not have ids field")
 	}
 	modelMap, err := dynamodbattribute.MarshalMap(model)
 	if err != nil {
 		return 0, err
 	}
-	params := &dynamodb.PutItemInput{
-		TableName:              aws.String(tableName),
-		Expected:               expected,
-		Item:                   modelMap,
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-	output, err := db.PutItemWithContext(ctx, params)
-	if err != nil {
-		if strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0 {
-			return 0, fmt.Errorf("object not found")
-		}
-		return 0, err
-	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+	opts := getWriteOptions(options)
+	return putItem(ctx, db, tableName, modelMap, And(existsCondition(keys, opts), opts.Condition), updateFailed, opts)
 }
 
-func UpdateOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, versionIndex int, versionField string) (int64, error) {
+func UpdateOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, versionIndex int, versionField string, options ...WriteOptions) (int64, error) {
 	ids := getIdValueFromModel(model, keys)
-	modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
-	oldModel := reflect.New(modelType).Interface()
-	itemExist, err := FindOneAndDecode(ctx, db, tableName, keys, ids, oldModel)
-	if err != nil {
-		return 0, err
-	}
-	if !itemExist {
-		return 0, fmt.Errorf("not found")
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("cannot update one an Object that do not have ids field")
 	}
+	modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
 	versionType := modelType.Field(versionIndex).Type.String()
 	if ok := strings.Contains(versionType, "int"); !ok {
 		return 0, fmt.Errorf("not support type's version: %v", versionType)
 	}
 	currentVersion := reflect.ValueOf(getFieldValueAtIndex(model, versionIndex)).Int()
-	oldVersion := reflect.ValueOf(getFieldValueAtIndex(oldModel, versionIndex)).Int()
-	if currentVersion != oldVersion {
-		return -1, fmt.Errorf("wrong version")
-	}
-	nextVersion := currentVersion + 1
-	expected, err := buildKeyMapWithExpected(keys, ids, true)
-	if err != nil {
-		return 0, err
-	}
 	modelMap, err := dynamodbattribute.MarshalMap(model)
 	if err != nil {
 		return 0, err
 	}
-	modelMap[versionField] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(nextVersion, 10))}
-	params := &dynamodb.PutItemInput{
-		TableName:              aws.String(tableName),
-		Expected:               expected,
-		Item:                   modelMap,
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	modelMap[versionField] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(currentVersion+1, 10))}
+	opts := getWriteOptions(options)
+	condition := And(existsCondition(keys, opts), versionEqual(versionField, currentVersion), opts.Condition)
+	res, err := putItem(ctx, db, tableName, modelMap, condition, versionFailed(versionField, currentVersion), opts)
+	if err != nil && err.Error() == WrongVersion {
+		return -1, err
 	}
-	output, err := db.PutItemWithContext(ctx, params)
-	if err != nil {
-		if strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0 {
-			return 0, fmt.Errorf("object not found")
-		}
-		return 0, err
-	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+	return res, err
 }
 
-func UpsertOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}) (int64, error) {
+func UpsertOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, options ...WriteOptions) (int64, error) {
 	modelMap, err := dynamodbattribute.MarshalMap(model)
 	if err != nil {
 		return 0, err
 	}
-	var strWhere string
-	if len(keys) > 0 {
-		strWhere = fmt.Sprintf("attribute_exists(%s)", strings.Join(keys, ","))
-	}
-	params := &dynamodb.PutItemInput{
-		TableName:              aws.String(tableName),
-		Item:                   modelMap,
-		ConditionExpression: 	aws.String(strWhere),
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-	output, err := db.PutItemWithContext(ctx, params)
-	if err != nil {
-		if strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0 {
-			return 0, fmt.Errorf("object not found")
-		}
-		return 0, err
-	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+	opts := getWriteOptions(options)
+	return putItem(ctx, db, tableName, modelMap, opts.Condition, conditionFailed, opts)
 }
 
-func UpsertOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, versionIndex int, versionField string) (int64, error) {
+func UpsertOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, versionIndex int, versionField string, options ...WriteOptions) (int64, error) {
 	ids := getIdValueFromModel(model, keys)
-	itemExist, err := Exist(ctx, db, tableName, keys, ids)
+	item, err := GetItem(ctx, db, tableName, keys, ids)
 	if err != nil {
 		if errNotFound := strings.Contains(err.Error(), "not found"); !errNotFound {
 			return 0, err
 		}
 	}
+	opts := getWriteOptions(options)
+	itemExist := len(item) > 0 && (opts.SoftDelete == nil || !opts.SoftDelete.IsDeleted(item))
 	if itemExist {
-		return UpdateOneWithVersion(ctx, db, tableName, keys, model, versionIndex, versionField)
+		return UpdateOneWithVersion(ctx, db, tableName, keys, model, versionIndex, versionField, options...)
 	} else {
-		return InsertOneWithVersion(ctx, db, tableName, keys, model, versionIndex, versionField)
+		return InsertOneWithVersion(ctx, db, tableName, keys, model, versionIndex, versionField, options...)
 	}
 }
 
-func DeleteOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}) (int64, error) {
+func DeleteOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, options ...WriteOptions) (int64, error) {
 	keyMap, err := buildKeyMap(keys, id)
 	if err != nil {
 		return 0, err
 	}
-	params := &dynamodb.DeleteItemInput{
-		TableName:              aws.String(tableName),
-		Key:                    keyMap,
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-	output, err := db.DeleteItemWithContext(ctx, params)
-	if err != nil {
-		return 0, err
-	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+	opts := getWriteOptions(options)
+	return deleteItem(ctx, db, tableName, keyMap, And(keyExists(keys), opts.Condition), updateFailed, opts)
 }
 
-func PatchOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model map[string]interface{}) (int64, error) {
-	idMap := map[string]interface{}{}
-	for i := range keys {
-		idMap[keys[i]] = model[keys[i]]
-		delete(model, keys[i])
-	}
-	keyMap, err := buildKeyMap(keys, idMap)
-	if err != nil {
-		return 0, err
-	}
-	updateBuilder := expression.UpdateBuilder{}
-	for key, value := range model {
-		updateBuilder = updateBuilder.Set(expression.Name(key), expression.Value(value))
-	}
-	var cond expression.ConditionBuilder
-	for key, value := range idMap {
-		if reflect.ValueOf(cond).IsZero() {
-			cond = expression.Name(key).Equal(expression.Value(value))
-		}
-		cond = cond.And(expression.Name(key).Equal(expression.Value(value)))
-	}
-	expr, _ := expression.NewBuilder().WithUpdate(updateBuilder).WithCondition(cond).Build()
-	input := &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(tableName),
-		Key:                       keyMap,
-		ConditionExpression:       expr.Condition(),
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		UpdateExpression:          expr.Update(),
-		ReturnConsumedCapacity:    aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-	output, err := db.UpdateItemWithContext(ctx, input)
-	if err != nil {
-		if strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0 {
-			return 0, fmt.Errorf("object not found")
-		}
-		return 0, err
-	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+func PatchOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model map[string]interface{}, options ...WriteOptions) (int64, error) {
+	return patchOne(ctx, db, tableName, keys, model, nil, updateFailed, getWriteOptions(options))
 }
 
-func PatchOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model map[string]interface{}, versionField string) (int64, error) {
+func PatchOneWithVersion(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model map[string]interface{}, versionField string, options ...WriteOptions) (int64, error) {
 	ids := getIdValueFromMap(model, keys)
 	if len(ids) == 0 {
 		return 0, fmt.Errorf("cannot patch one an Object that do not have ids field")
 	}
-	itemExist, oldModel, err := FindOneAndReturnMapData(ctx, db, tableName, keys, ids)
-	if err != nil {
-		return 0, err
-	}
-	if !itemExist {
-		return 0, fmt.Errorf("item not found")
-
-	}
 	currentVersion := reflect.ValueOf(model[versionField])
 	versionType := currentVersion.Kind().String()
-	oldVersion := oldModel[versionField]
-	if ok := strings.Contains(versionType, "int"); !ok {
+	var version int64
+	if strings.Contains(versionType, "int") {
+		version = currentVersion.Int()
+	} else if strings.Contains(versionType, "float") && currentVersion.Float() == float64(int64(currentVersion.Float())) {
+		version = int64(currentVersion.Float())
+	} else {
 		return 0, fmt.Errorf("not support type's version: %v", versionType)
 	}
+	model[versionField] = version + 1
+	res, err := patchOne(ctx, db, tableName, keys, model, versionEqual(versionField, version), versionFailed(versionField, version), getWriteOptions(options))
+	if err != nil && err.Error() == WrongVersion {
+		return -1, err
+	}
+	return res, err
+}
 
-	if float64(currentVersion.Int()) != oldVersion {
-		return -1, fmt.Errorf("wrong version")
+// UpdateOneWithProtected replaces the item like UpdateOne, but the protected attributes keep their stored values.
+func UpdateOneWithProtected(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, protected []string, options ...WriteOptions) (int64, error) {
+	opts := getWriteOptions(options)
+	return putProtected(ctx, db, tableName, keys, model, protected, nil, true, opts.Condition, updateFailed, opts)
+}
 
-	}
-	nextVersion := currentVersion.Int() + 1
-	expected, err := buildKeyMapWithExpected(keys, ids, true)
+// UpsertOneWithProtected replaces or inserts the item like UpsertOne, but the protected attributes of an existing item keep their stored values.
+func UpsertOneWithProtected(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, protected []string, options ...WriteOptions) (int64, error) {
+	opts := getWriteOptions(options)
+	return putProtected(ctx, db, tableName, keys, model, protected, nil, false, opts.Condition, updateFailed, opts)
+}
+
+// putProtected reads the protected attributes of the stored item and puts the model with them.
+// The put is conditioned on the protected attributes being unchanged, or on the item not existing if it was not found.
+func putProtected(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model interface{}, protected []string, values map[string]*dynamodb.AttributeValue, mustExist bool, condition *expression.ConditionBuilder, message func(map[string]*dynamodb.AttributeValue) string, options WriteOptions) (int64, error) {
+	item, err := dynamodbattribute.MarshalMap(model)
 	if err != nil {
 		return 0, err
 	}
-	modelMap, err := dynamodbattribute.MarshalMap(model)
+	for name, value := range values {
+		item[name] = value
+	}
+	keyMap := map[string]*dynamodb.AttributeValue{}
+	for _, key := range keys {
+		v, ok := item[key]
+		if !ok {
+			return 0, fmt.Errorf("cannot update one an Object that do not have ids field")
+		}
+		keyMap[key] = v
+	}
+	output, err := db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            keyMap,
+		ConsistentRead: aws.Bool(true),
+	})
 	if err != nil {
 		return 0, err
 	}
-	modelMap[versionField] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(nextVersion, 10))}
-	params := &dynamodb.PutItemInput{
-		TableName:              aws.String(tableName),
-		Expected:               expected,
-		Item:                   modelMap,
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	if len(output.Item) == 0 || (options.SoftDelete != nil && options.SoftDelete.IsDeleted(output.Item)) {
+		if mustExist {
+			return 0, &ConditionalCheckFailedError{Message: message(nil)}
+		}
+		return putItem(ctx, db, tableName, item, And(notExistsCondition(keys, options), condition), message, options)
+	}
+	conditions := []*expression.ConditionBuilder{existsCondition(keys, options), condition}
+	for _, name := range protected {
+		var c expression.ConditionBuilder
+		if v, ok := output.Item[name]; ok {
+			item[name] = v
+			c = expression.Name(name).Equal(expression.Value(v))
+		} else {
+			c = expression.AttributeNotExists(expression.Name(name))
+		}
+		conditions = append(conditions, &c)
+	}
+	return putItem(ctx, db, tableName, item, And(conditions...), message, options)
+}
+
+func patchOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, model map[string]interface{}, condition *expression.ConditionBuilder, message func(map[string]*dynamodb.AttributeValue) string, options WriteOptions) (int64, error) {
+	idMap := map[string]interface{}{}
+	for i := range keys {
+		idMap[keys[i]] = model[keys[i]]
+		delete(model, keys[i])
 	}
-	output, err := db.PutItemWithContext(ctx, params)
+	keyMap, err := buildKeyMap(keys, idMap)
 	if err != nil {
-		if strings.Index(err.Error(), "ConditionalCheckFailedException:") >= 0 {
-			return 0, fmt.Errorf("object not found")
-		}
 		return 0, err
 	}
-	return int64(aws.Float64Value(output.ConsumedCapacity.CapacityUnits)), nil
+	updateBuilder := expression.UpdateBuilder{}
+	for key, value := range model {
+		updateBuilder = updateBuilder.Set(expression.Name(key), expression.Value(value))
+	}
+	return updateItem(ctx, db, tableName, keyMap, updateBuilder, And(existsCondition(keys, options), condition, options.Condition), message, options)
 }
 
 func GetFieldByName(modelType reflect.Type, fieldName string) (int, string, bool) {
@@ -676,6 +664,40 @@ func FindTableDescription(db *dynamodb.DynamoDB, tableName string) (*dynamodb.Ta
 	return result.Table, nil
 }
 
+// GetSecondaryIndexes returns the key schema of the table, with an empty IndexName, followed by its global and local secondary indexes.
+func GetSecondaryIndexes(table *dynamodb.TableDescription) []SecondaryIndex {
+	indexes := []SecondaryIndex{{Keys: getKeyNames(table.KeySchema)}}
+	for _, index := range table.GlobalSecondaryIndexes {
+		indexes = append(indexes, newSecondaryIndex(index.IndexName, index.KeySchema, index.Projection))
+	}
+	for _, index := range table.LocalSecondaryIndexes {
+		indexes = append(indexes, newSecondaryIndex(index.IndexName, index.KeySchema, index.Projection))
+	}
+	return indexes
+}
+
+func newSecondaryIndex(indexName *string, keySchema []*dynamodb.KeySchemaElement, projection *dynamodb.Projection) SecondaryIndex {
+	index := SecondaryIndex{IndexName: aws.StringValue(indexName), Keys: getKeyNames(keySchema)}
+	if projection != nil {
+		index.Projection = aws.StringValue(projection.ProjectionType)
+		index.NonKeyAttributes = aws.StringValueSlice(projection.NonKeyAttributes)
+	}
+	return index
+}
+
+// getKeyNames returns the partition key then the sort key.
+func getKeyNames(keySchema []*dynamodb.KeySchemaElement) []string {
+	keys := make([]string, 0, len(keySchema))
+	for _, key := range keySchema {
+		if aws.StringValue(key.KeyType) == dynamodb.KeyTypeHash {
+			keys = append([]string{aws.StringValue(key.AttributeName)}, keys...)
+		} else {
+			keys = append(keys, aws.StringValue(key.AttributeName))
+		}
+	}
+	return keys
+}
+
 func MapToDBObject(object map[string]interface{}, objectMap map[string]string) map[string]interface{} {
 	result := make(map[string]interface{})
 	for key, value := range object {
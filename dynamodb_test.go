@@ -0,0 +1,66 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"testing"
+	"time"
+)
+
+func TestGetItemsRetriesUnprocessedKeys(t *testing.T) {
+	backoff := batchGetBackoff
+	batchGetBackoff = time.Millisecond
+	defer func() {
+		batchGetBackoff = backoff
+	}()
+	tests := []struct {
+		name      string
+		throttled int
+		calls     int
+		items     int
+		err       bool
+	}{
+		{"no unprocessed key", 0, 1, 3, false},
+		{"unprocessed keys", 2, 3, 3, false},
+		{"retries exhausted", 100, BatchGetAttempts, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			db, _ := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				var input dynamodb.BatchGetItemInput
+				r.Decode(t, &input)
+				calls++
+				if calls <= tt.throttled {
+					return &dynamodb.BatchGetItemOutput{UnprocessedKeys: input.RequestItems}
+				}
+				return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{"accounts": input.RequestItems["accounts"].Keys}}
+			})
+			items, err := GetItems(context.Background(), db, "accounts", []string{"id"}, []string{"a1", "a2", "a3"})
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if calls != tt.calls || len(items) != tt.items {
+				t.Errorf("%d calls and %d items, want %d calls and %d items", calls, len(items), tt.calls, tt.items)
+			}
+		})
+	}
+}
+
+func TestGetItemsStopsWhenContextIsDone(t *testing.T) {
+	db, _ := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		var input dynamodb.BatchGetItemInput
+		r.Decode(t, &input)
+		return &dynamodb.BatchGetItemOutput{UnprocessedKeys: input.RequestItems}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := GetItems(ctx, db, "accounts", []string{"id"}, []string{"a1"})
+	if err == nil {
+		t.Error("GetItems returns no error when the context is done")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetItems returns after %v", elapsed)
+	}
+}
@@ -0,0 +1,167 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LeaseClient is the lease operations used by Elector, LockClient implements it.
+type LeaseClient interface {
+	AcquireLease(ctx context.Context, key string, owner string, lease time.Duration) (int64, error)
+	RenewLease(ctx context.Context, key string, owner string, token int64, lease time.Duration) error
+	ReleaseLease(ctx context.Context, key string, owner string, token int64) error
+	GetLease(ctx context.Context, key string) (string, time.Time, error)
+}
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Elector competes for the lease Name; the leader renews it every RenewInterval and steps down before it expires if the renewal keeps failing.
+type Elector struct {
+	Client        LeaseClient
+	Name          string
+	Identity      string
+	LeaseDuration time.Duration
+	RenewInterval time.Duration
+	RetryInterval time.Duration
+	Clock         Clock
+	// OnStartedLeading runs in its own goroutine, it must return when its context is cancelled.
+	OnStartedLeading func(ctx context.Context)
+	OnStoppedLeading func()
+	mu               sync.RWMutex
+	leader           string
+}
+
+func NewElector(client LeaseClient, name string, identity string, leaseDuration time.Duration, onStartedLeading func(ctx context.Context), onStoppedLeading func()) *Elector {
+	return &Elector{
+		Client:           client,
+		Name:             name,
+		Identity:         identity,
+		LeaseDuration:    leaseDuration,
+		RenewInterval:    leaseDuration / 3,
+		RetryInterval:    leaseDuration / 3,
+		Clock:            systemClock{},
+		OnStartedLeading: onStartedLeading,
+		OnStoppedLeading: onStoppedLeading,
+	}
+}
+
+// Leader returns the identity of the last known leader, it is empty if there is no leader.
+func (e *Elector) Leader() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.Leader() == e.Identity
+}
+
+func (e *Elector) setLeader(leader string) {
+	e.mu.Lock()
+	e.leader = leader
+	e.mu.Unlock()
+}
+
+func (e *Elector) clock() Clock {
+	if e.Clock == nil {
+		return systemClock{}
+	}
+	return e.Clock
+}
+
+// Run campaigns and leads until the context is cancelled, it always returns the error of the context.
+func (e *Elector) Run(ctx context.Context) error {
+	for {
+		start := e.clock().Now()
+		if token, ok := e.campaign(ctx); ok {
+			e.lead(ctx, token, start)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-e.clock().After(e.RetryInterval):
+		}
+	}
+}
+
+func (e *Elector) campaign(ctx context.Context) (int64, bool) {
+	token, err := e.Client.AcquireLease(ctx, e.Name, e.Identity, e.LeaseDuration)
+	if err == nil {
+		return token, true
+	}
+	if err == ErrLockHeld {
+		if leader, _, er2 := e.Client.GetLease(ctx, e.Name); er2 == nil {
+			e.setLeader(leader)
+		}
+	}
+	return 0, false
+}
+
+// lead runs OnStartedLeading and renews the lease. Once the lease is lost, cannot be renewed in time or the context is cancelled,
+// it waits for OnStartedLeading to return, then calls OnStoppedLeading and releases the lease.
+func (e *Elector) lead(ctx context.Context, token int64, start time.Time) {
+	clock := e.clock()
+	// The deadline is computed from the time before the request, so it is never later than the expiry in the table.
+	deadline := start.Add(e.LeaseDuration)
+	e.setLeader(e.Identity)
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if e.OnStartedLeading != nil {
+			e.OnStartedLeading(leadCtx)
+		}
+	}()
+	lost := false
+renewal:
+	for {
+		select {
+		case <-ctx.Done():
+			break renewal
+		case <-clock.After(e.RenewInterval):
+			start = clock.Now()
+			// The renewal must not outlast the lease, the timeout is computed with the clock of the elector.
+			renewCtx, cancelRenew := context.WithTimeout(ctx, deadline.Sub(start))
+			err := e.Client.RenewLease(renewCtx, e.Name, e.Identity, token, e.LeaseDuration)
+			cancelRenew()
+			if err == nil {
+				deadline = start.Add(e.LeaseDuration)
+				continue
+			}
+			// Keep leading after a transient error only if the lease is still valid at the next renewal.
+			if err == ErrLockLost || !clock.Now().Add(e.RenewInterval).Before(deadline) {
+				lost = true
+				break renewal
+			}
+		}
+	}
+	// Another node may lead once the lease is released or expired, so the work of this node must have stopped.
+	cancel()
+	<-done
+	e.setLeader("")
+	if e.OnStoppedLeading != nil {
+		e.OnStoppedLeading()
+	}
+	if !lost {
+		releaseCtx, cancelRelease := context.WithTimeout(context.Background(), e.RenewInterval)
+		e.Client.ReleaseLease(releaseCtx, e.Name, e.Identity, token)
+		cancelRelease()
+	}
+}
@@ -0,0 +1,215 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// fakeClock fires the channels of After when Advance reaches their time, and signals each call of After.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	waits  chan time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0), waits: make(chan time.Duration, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	c.mu.Unlock()
+	c.waits <- d
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var timers []fakeTimer
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			timers = append(timers, t)
+		} else {
+			t.ch <- c.now
+		}
+	}
+	c.timers = timers
+}
+
+// waitAfter waits until the elector waits for d, which means that it has handled the previous step.
+func (c *fakeClock) waitAfter(t *testing.T, d time.Duration) {
+	t.Helper()
+	select {
+	case got := <-c.waits:
+		if got != d {
+			t.Fatalf("elector waits for %v, want %v", got, d)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("elector does not wait for %v", d)
+	}
+}
+
+type fakeLeaseClient struct {
+	mu        sync.Mutex
+	acquired  bool
+	renewals  []error
+	renewed   int
+	deadlines []time.Duration
+	released  int
+	record    func(event string)
+}
+
+func (c *fakeLeaseClient) AcquireLease(ctx context.Context, key string, owner string, lease time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.acquired {
+		return 0, ErrLockHeld
+	}
+	c.acquired = true
+	return 1, nil
+}
+
+func (c *fakeLeaseClient) RenewLease(ctx context.Context, key string, owner string, token int64, lease time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if deadline, ok := ctx.Deadline(); ok {
+		c.deadlines = append(c.deadlines, time.Until(deadline))
+	}
+	var err error
+	if c.renewed < len(c.renewals) {
+		err = c.renewals[c.renewed]
+	}
+	c.renewed++
+	return err
+}
+
+func (c *fakeLeaseClient) ReleaseLease(ctx context.Context, key string, owner string, token int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.released++
+	c.record("released")
+	return nil
+}
+
+func (c *fakeLeaseClient) GetLease(ctx context.Context, key string) (string, time.Time, error) {
+	return "node-2", time.Time{}, nil
+}
+
+func TestElector(t *testing.T) {
+	transient := errors.New("throttled")
+	tests := []struct {
+		name     string
+		renewals []error
+		// steps is the number of renewal intervals before the context is cancelled.
+		steps    int
+		renewed  int
+		lost     bool
+		released int
+	}{
+		{"renewal", []error{nil, nil}, 2, 2, false, 1},
+		{"transient error", []error{transient, nil}, 2, 2, false, 1},
+		{"renewal failing until the lease expires", []error{transient, transient}, 2, 2, true, 0},
+		{"lease loss", []error{ErrLockLost}, 1, 1, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var events []string
+			record := func(event string) {
+				mu.Lock()
+				events = append(events, event)
+				mu.Unlock()
+			}
+			clock := newFakeClock()
+			client := &fakeLeaseClient{renewals: tt.renewals, record: record}
+			started := make(chan struct{})
+			e := NewElector(client, "job", "node-1", 90*time.Second, func(ctx context.Context) {
+				close(started)
+				<-ctx.Done()
+				time.Sleep(10 * time.Millisecond)
+				record("work stopped")
+			}, func() {
+				record("stopped leading")
+			})
+			e.Clock = clock
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error)
+			go func() {
+				result <- e.Run(ctx)
+			}()
+			clock.waitAfter(t, e.RenewInterval)
+			<-started
+			if !e.IsLeader() {
+				t.Fatal("elector does not lead after acquiring the lease")
+			}
+			for i := 0; i < tt.steps; i++ {
+				clock.Advance(e.RenewInterval)
+				if tt.lost && i == tt.steps-1 {
+					clock.waitAfter(t, e.RetryInterval)
+				} else {
+					clock.waitAfter(t, e.RenewInterval)
+				}
+			}
+			if e.IsLeader() == tt.lost {
+				t.Errorf("leading = %v, want %v", e.IsLeader(), !tt.lost)
+			}
+			if tt.lost && e.Leader() != "" {
+				t.Errorf("leader = %q after losing the lease", e.Leader())
+			}
+			cancel()
+			if err := <-result; err != context.Canceled {
+				t.Errorf("Run = %v, want %v", err, context.Canceled)
+			}
+			client.mu.Lock()
+			defer client.mu.Unlock()
+			if client.renewed != tt.renewed {
+				t.Errorf("renewals = %d, want %d", client.renewed, tt.renewed)
+			}
+			if client.released != tt.released {
+				t.Errorf("releases = %d, want %d", client.released, tt.released)
+			}
+			for _, d := range client.deadlines {
+				if d <= 0 || d > e.LeaseDuration {
+					t.Errorf("renewal timeout = %v, want at most the remaining lease", d)
+				}
+			}
+			if len(client.deadlines) != client.renewed {
+				t.Errorf("%d renewals without timeout", client.renewed-len(client.deadlines))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			want := []string{"work stopped", "stopped leading", "released"}
+			if tt.released == 0 {
+				want = want[:2]
+			}
+			if len(events) != len(want) {
+				t.Fatalf("events = %v, want %v", events, want)
+			}
+			for i := range want {
+				if events[i] != want[i] {
+					t.Errorf("events = %v, want %v", events, want)
+					break
+				}
+			}
+		})
+	}
+}
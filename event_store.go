@@ -0,0 +1,294 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var ErrWrongExpectedVersion = errors.New("wrong expected version")
+
+type Event struct {
+	StreamId  string
+	Version   int64
+	Type      string
+	Data      interface{}
+	CreatedAt time.Time
+}
+
+// EventSerializer converts the data of the events and snapshots to an attribute value.
+type EventSerializer interface {
+	Marshal(v interface{}) (*dynamodb.AttributeValue, error)
+	Unmarshal(data *dynamodb.AttributeValue, v interface{}) error
+}
+
+// JSONSerializer stores the data as a JSON string.
+type JSONSerializer struct{}
+
+func (JSONSerializer) Marshal(v interface{}) (*dynamodb.AttributeValue, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.AttributeValue{S: aws.String(string(data))}, nil
+}
+
+func (JSONSerializer) Unmarshal(data *dynamodb.AttributeValue, v interface{}) error {
+	if data == nil || data.S == nil {
+		return fmt.Errorf("event data is not a JSON string")
+	}
+	return json.Unmarshal([]byte(*data.S), v)
+}
+
+// AttributeSerializer stores the data as a native attribute value, usually a map.
+type AttributeSerializer struct{}
+
+func (AttributeSerializer) Marshal(v interface{}) (*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.Marshal(v)
+}
+
+func (AttributeSerializer) Unmarshal(data *dynamodb.AttributeValue, v interface{}) error {
+	return dynamodbattribute.Unmarshal(data, v)
+}
+
+// EventStore keeps the events of a stream in one partition, the sort key is the version of the event, starting at 1.
+// The snapshot of the stream is the item of version 0.
+type EventStore struct {
+	Database    *dynamodb.DynamoDB
+	tableName   string
+	streamName  string
+	versionName string
+	Serializer  EventSerializer
+	// types are the data types of the event types, the data of an unregistered type is decoded as interface{}.
+	types map[string]reflect.Type
+	Now   func() time.Time
+}
+
+// NewEventStore creates an event store; options are the names of the partition key and of the sort key.
+func NewEventStore(db *dynamodb.DynamoDB, tableName string, serializer EventSerializer, options ...string) *EventStore {
+	streamName, versionName := "streamId", "version"
+	if len(options) >= 1 && len(options[0]) > 0 {
+		streamName = options[0]
+	}
+	if len(options) >= 2 && len(options[1]) > 0 {
+		versionName = options[1]
+	}
+	if serializer == nil {
+		serializer = JSONSerializer{}
+	}
+	return &EventStore{Database: db, tableName: tableName, streamName: streamName, versionName: versionName, Serializer: serializer, types: make(map[string]reflect.Type)}
+}
+
+// Register sets the data type of the event type from a sample value.
+func (s *EventStore) Register(eventType string, sample interface{}) {
+	t := reflect.TypeOf(sample)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s.types[eventType] = t
+}
+
+func (s *EventStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *EventStore) keyMap(streamId string, version int64) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		s.streamName:  {S: aws.String(streamId)},
+		s.versionName: {N: aws.String(strconv.FormatInt(version, 10))},
+	}
+}
+
+// Append writes the events after expectedVersion, the version of the last event of the stream (0 for a new stream), and returns the new version.
+// It returns ErrWrongExpectedVersion if the stream has another version; at most 99 events can be appended at once.
+func (s *EventStore) Append(ctx context.Context, streamId string, expectedVersion int64, events ...Event) (int64, error) {
+	if len(events) == 0 {
+		return expectedVersion, nil
+	}
+	if len(events) > 99 {
+		return expectedVersion, fmt.Errorf("cannot append more than 99 events at once")
+	}
+	now := s.now()
+	var items []*dynamodb.TransactWriteItem
+	if expectedVersion > 0 {
+		items = append(items, &dynamodb.TransactWriteItem{ConditionCheck: &dynamodb.ConditionCheck{
+			TableName:                aws.String(s.tableName),
+			Key:                      s.keyMap(streamId, expectedVersion),
+			ConditionExpression:      aws.String("attribute_exists(#version)"),
+			ExpressionAttributeNames: map[string]*string{"#version": aws.String(s.versionName)},
+		}})
+	}
+	for i, event := range events {
+		data, err := s.Serializer.Marshal(event.Data)
+		if err != nil {
+			return expectedVersion, err
+		}
+		createdAt := event.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		item := s.keyMap(streamId, expectedVersion+int64(i)+1)
+		item["type"] = &dynamodb.AttributeValue{S: aws.String(event.Type)}
+		item["data"] = data
+		item["createdAt"] = &dynamodb.AttributeValue{S: aws.String(createdAt.Format(time.RFC3339Nano))}
+		items = append(items, &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+			TableName:                aws.String(s.tableName),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#version)"),
+			ExpressionAttributeNames: map[string]*string{"#version": aws.String(s.versionName)},
+		}})
+	}
+	_, err := s.Database.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		if e, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for _, reason := range e.CancellationReasons {
+				if aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+					return expectedVersion, ErrWrongExpectedVersion
+				}
+			}
+		}
+		return expectedVersion, err
+	}
+	return expectedVersion + int64(len(events)), nil
+}
+
+// Load returns all events of the stream from the version fromVersion.
+func (s *EventStore) Load(ctx context.Context, streamId string, fromVersion int64) ([]Event, error) {
+	var events []Event
+	for {
+		page, next, err := s.LoadPage(ctx, streamId, fromVersion, 0)
+		events = append(events, page...)
+		if err != nil || next <= 0 {
+			return events, err
+		}
+		fromVersion = next
+	}
+}
+
+// LoadPage returns the events of the stream from the version fromVersion, limited to a page of limit items (0 is the page size of DynamoDB).
+// The next version to load is 0 if there are no more events.
+func (s *EventStore) LoadPage(ctx context.Context, streamId string, fromVersion int64, limit int64) ([]Event, int64, error) {
+	if fromVersion < 1 {
+		fromVersion = 1
+	}
+	keyCondition := expression.Key(s.streamName).Equal(expression.Value(streamId)).
+		And(expression.Key(s.versionName).GreaterThanEqual(expression.Value(fromVersion)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, 0, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConsistentRead:            aws.Bool(true),
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+	output, err := s.Database.QueryWithContext(ctx, input)
+	if err != nil {
+		return nil, 0, err
+	}
+	events := make([]Event, 0, len(output.Items))
+	for _, item := range output.Items {
+		event, er2 := s.decode(streamId, item)
+		if er2 != nil {
+			return events, 0, er2
+		}
+		events = append(events, event)
+	}
+	if len(output.LastEvaluatedKey) == 0 || len(events) == 0 {
+		return events, 0, nil
+	}
+	return events, events[len(events)-1].Version + 1, nil
+}
+
+func (s *EventStore) decode(streamId string, item map[string]*dynamodb.AttributeValue) (Event, error) {
+	event := Event{StreamId: streamId}
+	if v, ok := item[s.versionName]; ok && v.N != nil {
+		event.Version, _ = strconv.ParseInt(*v.N, 10, 64)
+	}
+	if v, ok := item["type"]; ok && v.S != nil {
+		event.Type = *v.S
+	}
+	if v, ok := item["createdAt"]; ok && v.S != nil {
+		event.CreatedAt, _ = time.Parse(time.RFC3339Nano, *v.S)
+	}
+	if t, ok := s.types[event.Type]; ok {
+		data := reflect.New(t)
+		if err := s.Serializer.Unmarshal(item["data"], data.Interface()); err != nil {
+			return event, err
+		}
+		event.Data = data.Elem().Interface()
+	} else {
+		var data interface{}
+		if err := s.Serializer.Unmarshal(item["data"], &data); err != nil {
+			return event, err
+		}
+		event.Data = data
+	}
+	return event, nil
+}
+
+// SaveSnapshot stores the state of the stream at the version, an older snapshot does not replace a newer one.
+func (s *EventStore) SaveSnapshot(ctx context.Context, streamId string, version int64, state interface{}) error {
+	data, err := s.Serializer.Marshal(state)
+	if err != nil {
+		return err
+	}
+	item := s.keyMap(streamId, 0)
+	item["snapshotVersion"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(version, 10))}
+	item["data"] = data
+	item["createdAt"] = &dynamodb.AttributeValue{S: aws.String(s.now().Format(time.RFC3339Nano))}
+	snapshotVersion := expression.Name("snapshotVersion")
+	older := expression.AttributeNotExists(snapshotVersion).Or(snapshotVersion.LessThan(expression.Value(version)))
+	_, err = putItem(ctx, s.Database, s.tableName, item, &older, conditionFailed, WriteOptions{})
+	if IsConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// LoadSnapshot decodes the snapshot of the stream into state and returns its version, 0 if there is no snapshot.
+func (s *EventStore) LoadSnapshot(ctx context.Context, streamId string, state interface{}) (int64, error) {
+	output, err := s.Database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            s.keyMap(streamId, 0),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || len(output.Item) == 0 {
+		return 0, err
+	}
+	v, ok := output.Item["snapshotVersion"]
+	if !ok || v.N == nil {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(*v.N, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return version, s.Serializer.Unmarshal(output.Item["data"], state)
+}
+
+// LoadFromSnapshot decodes the snapshot into state and returns its version and the events after it.
+func (s *EventStore) LoadFromSnapshot(ctx context.Context, streamId string, state interface{}) (int64, []Event, error) {
+	version, err := s.LoadSnapshot(ctx, streamId, state)
+	if err != nil {
+		return version, nil, err
+	}
+	events, err := s.Load(ctx, streamId, version+1)
+	return version, events, err
+}
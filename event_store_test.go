@@ -0,0 +1,267 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type deposited struct {
+	Amount int64 `json:"amount" dynamodbav:"amount"`
+}
+
+type balance struct {
+	Total int64 `json:"total" dynamodbav:"total"`
+}
+
+// streamTable keeps the items of one stream by version and answers the requests of the event store.
+type streamTable struct {
+	mu    sync.Mutex
+	items map[int64]map[string]*dynamodb.AttributeValue
+}
+
+func number(v *dynamodb.AttributeValue) int64 {
+	n, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+	return n
+}
+
+func (s *streamTable) handle(t *testing.T) func(r fakeRequest) interface{} {
+	return func(r fakeRequest) interface{} {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Operation {
+		case "TransactWriteItems":
+			var input dynamodb.TransactWriteItemsInput
+			r.Decode(t, &input)
+			reasons := make([]*dynamodb.CancellationReason, len(input.TransactItems))
+			failed := false
+			for i, item := range input.TransactItems {
+				reasons[i] = &dynamodb.CancellationReason{Code: aws.String("None")}
+				var conflict bool
+				if item.ConditionCheck != nil {
+					_, exists := s.items[number(item.ConditionCheck.Key["version"])]
+					conflict = !exists
+				} else {
+					_, conflict = s.items[number(item.Put.Item["version"])]
+				}
+				if conflict {
+					reasons[i].Code = aws.String("ConditionalCheckFailed")
+					failed = true
+				}
+			}
+			if failed {
+				return fakeError{Code: dynamodb.ErrCodeTransactionCanceledException, Reasons: reasons}
+			}
+			for _, item := range input.TransactItems {
+				if item.Put != nil {
+					s.items[number(item.Put.Item["version"])] = item.Put.Item
+				}
+			}
+		case "Query":
+			var input dynamodb.QueryInput
+			r.Decode(t, &input)
+			var from int64
+			for _, v := range input.ExpressionAttributeValues {
+				if v.N != nil {
+					from = number(v)
+				}
+			}
+			var versions []int64
+			for v := range s.items {
+				if v >= from {
+					versions = append(versions, v)
+				}
+			}
+			sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+			output := &dynamodb.QueryOutput{}
+			for _, v := range versions {
+				if input.Limit != nil && int64(len(output.Items)) == *input.Limit {
+					output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"version": output.Items[len(output.Items)-1]["version"]}
+					break
+				}
+				output.Items = append(output.Items, s.items[v])
+			}
+			return output
+		case "GetItem":
+			return &dynamodb.GetItemOutput{Item: s.items[0]}
+		case "PutItem":
+			var input dynamodb.PutItemInput
+			r.Decode(t, &input)
+			if snapshot, ok := s.items[0]; ok && number(snapshot["snapshotVersion"]) >= number(input.Item["snapshotVersion"]) {
+				return fakeError{Code: dynamodb.ErrCodeConditionalCheckFailedException}
+			}
+			s.items[0] = input.Item
+		}
+		return nil
+	}
+}
+
+func newTestEventStore(t *testing.T, serializer EventSerializer) (*EventStore, *fakeDynamoDB) {
+	table := &streamTable{items: make(map[int64]map[string]*dynamodb.AttributeValue)}
+	db, fake := newFakeDynamoDB(t, table.handle(t))
+	s := NewEventStore(db, "events", serializer)
+	s.Register("Deposited", deposited{})
+	s.Now = func() time.Time {
+		return time.Unix(1700000000, 0).UTC()
+	}
+	return s, fake
+}
+
+func deposits(amounts ...int64) []Event {
+	var events []Event
+	for _, amount := range amounts {
+		events = append(events, Event{Type: "Deposited", Data: deposited{Amount: amount}})
+	}
+	return events
+}
+
+func TestEventStoreAppend(t *testing.T) {
+	s, fake := newTestEventStore(t, nil)
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		expected int64
+		events   []Event
+		version  int64
+		items    int
+		err      error
+	}{
+		{"new stream", 0, deposits(10, 20), 2, 2, nil},
+		{"next events", 2, deposits(30), 3, 2, nil},
+		{"stream already created", 0, deposits(40), 0, 1, ErrWrongExpectedVersion},
+		{"version already appended", 2, deposits(40), 2, 2, ErrWrongExpectedVersion},
+		{"version not appended yet", 5, deposits(40), 5, 2, ErrWrongExpectedVersion},
+		{"no event", 3, nil, 3, 0, nil},
+		{"largest append", 3, deposits(make([]int64, 99)...), 102, 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(fake.Requests(""))
+			version, err := s.Append(ctx, "s1", tt.expected, tt.events...)
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if version != tt.version {
+				t.Errorf("version = %d, want %d", version, tt.version)
+			}
+			requests := fake.Requests("")[before:]
+			if tt.items == 0 {
+				if len(requests) > 0 {
+					t.Errorf("%d requests, want none", len(requests))
+				}
+				return
+			}
+			var input dynamodb.TransactWriteItemsInput
+			requests[0].Decode(t, &input)
+			if len(input.TransactItems) != tt.items {
+				t.Errorf("%d items, want %d", len(input.TransactItems), tt.items)
+			}
+		})
+	}
+	before := len(fake.Requests(""))
+	if _, err := s.Append(ctx, "s1", 102, deposits(make([]int64, 100)...)...); err == nil {
+		t.Error("Append of 100 events returns no error")
+	}
+	if n := len(fake.Requests("")) - before; n > 0 {
+		t.Errorf("%d requests for 100 events, want none", n)
+	}
+}
+
+func TestEventStoreLoad(t *testing.T) {
+	tests := []struct {
+		name       string
+		serializer EventSerializer
+		data       func(item map[string]*dynamodb.AttributeValue) bool
+	}{
+		{"JSON", JSONSerializer{}, func(item map[string]*dynamodb.AttributeValue) bool {
+			return aws.StringValue(item["data"].S) == `{"amount":10}`
+		}},
+		{"attribute", AttributeSerializer{}, func(item map[string]*dynamodb.AttributeValue) bool {
+			return item["data"].M != nil && aws.StringValue(item["data"].M["amount"].N) == "10"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestEventStore(t, tt.serializer)
+			ctx := context.Background()
+			events := append(deposits(10, 20), Event{Type: "Opened", Data: map[string]interface{}{"owner": "u1"}})
+			if _, err := s.Append(ctx, "s1", 0, events...); err != nil {
+				t.Fatal(err)
+			}
+			var input dynamodb.TransactWriteItemsInput
+			fake.Requests("TransactWriteItems")[0].Decode(t, &input)
+			if item := input.TransactItems[0].Put.Item; !tt.data(item) || aws.StringValue(item["createdAt"].S) != "2023-11-14T22:13:20Z" {
+				t.Errorf("item = %v", item)
+			}
+
+			page, next, err := s.LoadPage(ctx, "s1", 0, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) != 2 || next != 3 {
+				t.Fatalf("LoadPage = %d events, next %d, want 2 events, next 3", len(page), next)
+			}
+			if page[0].Version != 1 || page[0].StreamId != "s1" || page[0].Data != (deposited{Amount: 10}) || !page[0].CreatedAt.Equal(s.Now()) {
+				t.Errorf("event = %+v, want the first deposit", page[0])
+			}
+			page, next, err = s.LoadPage(ctx, "s1", next, 2)
+			if err != nil || len(page) != 1 || next != 0 {
+				t.Fatalf("LoadPage = %d events, next %d, %v, want the last event", len(page), next, err)
+			}
+			if data, ok := page[0].Data.(map[string]interface{}); !ok || data["owner"] != "u1" {
+				t.Errorf("data = %#v, want the map of an unregistered type", page[0].Data)
+			}
+
+			all, err := s.Load(ctx, "s1", 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int64
+			for _, event := range all {
+				versions = append(versions, event.Version)
+			}
+			if !reflect.DeepEqual(versions, []int64{2, 3}) {
+				t.Errorf("versions = %v, want [2 3]", versions)
+			}
+		})
+	}
+}
+
+func TestEventStoreSnapshot(t *testing.T) {
+	for _, serializer := range []EventSerializer{JSONSerializer{}, AttributeSerializer{}} {
+		t.Run(reflect.TypeOf(serializer).Name(), func(t *testing.T) {
+			s, _ := newTestEventStore(t, serializer)
+			ctx := context.Background()
+			var state balance
+			version, events, err := s.LoadFromSnapshot(ctx, "s1", &state)
+			if err != nil || version != 0 || len(events) != 0 {
+				t.Fatalf("LoadFromSnapshot = %d, %d events, %v, want an empty stream", version, len(events), err)
+			}
+			if _, err := s.Append(ctx, "s1", 0, deposits(10, 20, 30)...); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SaveSnapshot(ctx, "s1", 2, balance{Total: 30}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SaveSnapshot(ctx, "s1", 1, balance{Total: 10}); err != nil {
+				t.Errorf("SaveSnapshot of an older version returns %v, want nil", err)
+			}
+			version, events, err = s.LoadFromSnapshot(ctx, "s1", &state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != 2 || state.Total != 30 {
+				t.Errorf("snapshot = %d, %+v, want the newer snapshot", version, state)
+			}
+			if len(events) != 1 || events[0].Version != 3 || events[0].Data != (deposited{Amount: 30}) {
+				t.Errorf("events = %+v, want the event after the snapshot", events)
+			}
+		})
+	}
+}
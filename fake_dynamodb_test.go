@@ -0,0 +1,102 @@
+package dynamodb
+
+import (
+	"bytes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeError is returned by a fake handler to answer with a DynamoDB error, such as dynamodb.ErrCodeConditionalCheckFailedException.
+type fakeError struct {
+	Code    string
+	Item    map[string]*dynamodb.AttributeValue
+	Reasons []*dynamodb.CancellationReason
+}
+
+type fakeRequest struct {
+	Operation string
+	body      []byte
+}
+
+// Decode unmarshals the request into the input type of its operation, such as *dynamodb.UpdateItemInput.
+func (r fakeRequest) Decode(t *testing.T, input interface{}) {
+	t.Helper()
+	if err := jsonutil.UnmarshalJSON(input, bytes.NewReader(r.body)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeDynamoDB is a DynamoDB endpoint which records the requests and answers with the output returned by handle.
+type fakeDynamoDB struct {
+	mu       sync.Mutex
+	requests []fakeRequest
+	handle   func(r fakeRequest) interface{}
+}
+
+func newFakeDynamoDB(t *testing.T, handle func(r fakeRequest) interface{}) (*dynamodb.DynamoDB, *fakeDynamoDB) {
+	f := &fakeDynamoDB{handle: handle}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+	return dynamodb.New(sess), f
+}
+
+func (f *fakeDynamoDB) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	target := req.Header.Get("X-Amz-Target")
+	r := fakeRequest{Operation: target[strings.Index(target, ".")+1:], body: body}
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.mu.Unlock()
+	var output interface{} = struct{}{}
+	if f.handle != nil {
+		if o := f.handle(r); o != nil {
+			output = o
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if e, ok := output.(fakeError); ok {
+		data, _ := jsonutil.BuildJSON(&struct {
+			Type    *string                             `locationName:"__type" type:"string"`
+			Message *string                             `locationName:"message" type:"string"`
+			Item    map[string]*dynamodb.AttributeValue `type:"map"`
+			Reasons []*dynamodb.CancellationReason      `locationName:"CancellationReasons" type:"list"`
+		}{Type: aws.String(e.Code), Message: aws.String(e.Code), Item: e.Item, Reasons: e.Reasons})
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(data)
+		return
+	}
+	data, err := jsonutil.BuildJSON(output)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+// Requests returns the recorded requests of the operation, or all requests if it is empty.
+func (f *fakeDynamoDB) Requests(operation string) []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var requests []fakeRequest
+	for _, r := range f.requests {
+		if len(operation) == 0 || r.Operation == operation {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
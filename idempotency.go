@@ -0,0 +1,174 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"time"
+)
+
+const (
+	IdempotencyInProgress = "in_progress"
+	IdempotencyCompleted  = "completed"
+)
+
+var (
+	ErrRequestInProgress = errors.New("request is in progress")
+	// ErrRequestTakenOver is returned when the in progress record timed out and was taken over by another attempt.
+	ErrRequestTakenOver = errors.New("request was taken over by another attempt")
+)
+
+type IdempotencyRecord struct {
+	Key         string `dynamodbav:"-"`
+	Status      string `dynamodbav:"status"`
+	Token       string `dynamodbav:"token,omitempty"`
+	LockedUntil int64  `dynamodbav:"lockedUntil,omitempty"`
+	Code        int    `dynamodbav:"code,omitempty"`
+	Response    []byte `dynamodbav:"response,omitempty"`
+}
+
+type IdempotencyStore struct {
+	Database      *dynamodb.DynamoDB
+	tableName     string
+	keyName       string
+	expiredAtName string
+	// TTL is the retention of the records, Timeout is the time after which an in progress record is stale and can be taken over.
+	TTL     time.Duration
+	Timeout time.Duration
+	Now     func() time.Time
+}
+
+// NewIdempotencyStore creates an idempotency store; options are the names of the key and of the TTL attribute.
+func NewIdempotencyStore(db *dynamodb.DynamoDB, tableName string, ttl time.Duration, timeout time.Duration, options ...string) *IdempotencyStore {
+	keyName, expiredAtName := "id", "expiredAt"
+	if len(options) >= 1 && len(options[0]) > 0 {
+		keyName = options[0]
+	}
+	if len(options) >= 2 && len(options[1]) > 0 {
+		expiredAtName = options[1]
+	}
+	return &IdempotencyStore{Database: db, tableName: tableName, keyName: keyName, expiredAtName: expiredAtName, TTL: ttl, Timeout: timeout}
+}
+
+func (s *IdempotencyStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *IdempotencyStore) keyMap(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{s.keyName: {S: &key}}
+}
+
+// Begin records the key as in progress and returns the token of the attempt.
+// For a completed request it returns the stored record instead; it returns ErrRequestInProgress if another attempt is running.
+func (s *IdempotencyStore) Begin(ctx context.Context, key string) (string, *IdempotencyRecord, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(b)
+	now := s.now()
+	record := IdempotencyRecord{Status: IdempotencyInProgress, Token: token, LockedUntil: toMillis(now.Add(s.Timeout))}
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return "", nil, err
+	}
+	item[s.keyName] = s.keyMap(key)[s.keyName]
+	item[s.expiredAtName], _ = dynamodbattribute.Marshal(TTL(now.Add(s.TTL)))
+	// A record can be replaced if it is expired but not yet deleted, or if it is in progress and stale.
+	stale := expression.Name("status").Equal(expression.Value(IdempotencyInProgress)).And(expression.Name("lockedUntil").LessThan(expression.Value(toMillis(now))))
+	free := expression.AttributeNotExists(expression.Name(s.keyName)).
+		Or(expression.Name(s.expiredAtName).LessThan(expression.Value(now.Unix()))).
+		Or(stale)
+	_, err = putItem(ctx, s.Database, s.tableName, item, &free, conditionFailed, WriteOptions{ReturnValuesOnConditionCheckFailure: true})
+	if err == nil {
+		return token, nil, nil
+	}
+	e, ok := err.(*ConditionalCheckFailedError)
+	if !ok {
+		return "", nil, err
+	}
+	var existing IdempotencyRecord
+	if _, err = e.Decode(&existing); err != nil {
+		return "", nil, err
+	}
+	if existing.Status != IdempotencyCompleted {
+		return "", nil, ErrRequestInProgress
+	}
+	existing.Key = key
+	return "", &existing, nil
+}
+
+// Complete saves the response of the attempt, the following Begin of the key return it until the record expires.
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, token string, code int, response []byte) error {
+	update := expression.Set(expression.Name("status"), expression.Value(IdempotencyCompleted)).
+		Set(expression.Name("code"), expression.Value(code)).
+		Set(expression.Name(s.expiredAtName), expression.Value(s.now().Add(s.TTL).Unix())).
+		Remove(expression.Name("lockedUntil"))
+	if len(response) > 0 {
+		update = update.Set(expression.Name("response"), expression.Value(response))
+	}
+	owned := s.owned(token)
+	_, err := updateItem(ctx, s.Database, s.tableName, s.keyMap(key), update, &owned, conditionFailed, WriteOptions{})
+	if IsConditionalCheckFailed(err) {
+		return ErrRequestTakenOver
+	}
+	return err
+}
+
+// Abort deletes the in progress record of the attempt, so that the request can be retried.
+func (s *IdempotencyStore) Abort(ctx context.Context, key string, token string) error {
+	owned := s.owned(token)
+	_, err := deleteItem(ctx, s.Database, s.tableName, s.keyMap(key), &owned, conditionFailed, WriteOptions{})
+	if IsConditionalCheckFailed(err) {
+		return ErrRequestTakenOver
+	}
+	return err
+}
+
+func (s *IdempotencyStore) owned(token string) expression.ConditionBuilder {
+	return expression.Name("status").Equal(expression.Value(IdempotencyInProgress)).And(expression.Name("token").Equal(expression.Value(token)))
+}
+
+// Do runs fn once per key: the response returned by fn and its status code are saved, and the response is set into result, a pointer to its type.
+// A duplicate request does not run fn, it decodes the saved response into result and returns the saved status code and true.
+// An error of fn is not saved, the record is deleted so that the request can be retried.
+func (s *IdempotencyStore) Do(ctx context.Context, key string, result interface{}, fn func(ctx context.Context) (int, interface{}, error)) (int, bool, error) {
+	token, record, err := s.Begin(ctx, key)
+	if err != nil {
+		return 0, false, err
+	}
+	if record != nil {
+		if len(record.Response) == 0 || result == nil {
+			return record.Code, true, nil
+		}
+		return record.Code, true, json.Unmarshal(record.Response, result)
+	}
+	code, res, err := fn(ctx)
+	if err != nil {
+		s.Abort(ctx, key, token)
+		return code, false, err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		s.Abort(ctx, key, token)
+		return code, false, err
+	}
+	if err = s.Complete(ctx, key, token, code, data); err != nil {
+		return code, false, err
+	}
+	if result == nil || res == nil {
+		return code, false, nil
+	}
+	if setResult(result, res) != nil {
+		return code, false, json.Unmarshal(data, result)
+	}
+	return code, false, nil
+}
@@ -0,0 +1,203 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"testing"
+	"time"
+)
+
+type payment struct {
+	Id     string  `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+func completedRecord(code string, response string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id":       {S: aws.String("k1")},
+		"status":   {S: aws.String(IdempotencyCompleted)},
+		"code":     {N: aws.String(code)},
+		"response": {B: []byte(response)},
+	}
+}
+
+func inProgressRecord() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id":          {S: aws.String("k1")},
+		"status":      {S: aws.String(IdempotencyInProgress)},
+		"token":       {S: aws.String("other")},
+		"lockedUntil": {N: aws.String("1700000060000")},
+	}
+}
+
+func newTestIdempotencyStore(db *dynamodb.DynamoDB) *IdempotencyStore {
+	s := NewIdempotencyStore(db, "requests", 24*time.Hour, time.Minute)
+	s.Now = func() time.Time {
+		return time.Unix(1700000000, 0)
+	}
+	return s
+}
+
+func TestIdempotencyBegin(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]*dynamodb.AttributeValue
+		token    bool
+		code     int
+		err      error
+	}{
+		{"new request", nil, true, 0, nil},
+		{"completed request", completedRecord("201", `{"id":"p1"}`), false, 201, nil},
+		{"request in progress", inProgressRecord(), false, 0, ErrRequestInProgress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				if tt.existing != nil {
+					return fakeError{Code: dynamodb.ErrCodeConditionalCheckFailedException, Item: tt.existing}
+				}
+				return nil
+			})
+			token, record, err := newTestIdempotencyStore(db).Begin(context.Background(), "k1")
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if (len(token) > 0) != tt.token {
+				t.Errorf("token = %q, want a token %v", token, tt.token)
+			}
+			if tt.code > 0 && (record == nil || record.Code != tt.code || record.Key != "k1") {
+				t.Errorf("record = %+v, want the completed record", record)
+			}
+			var input dynamodb.PutItemInput
+			fake.Requests("PutItem")[0].Decode(t, &input)
+			if v := input.Item["lockedUntil"]; v == nil || aws.StringValue(v.N) != "1700000060000" {
+				t.Errorf("lockedUntil = %v, want now + timeout", v)
+			}
+			if v := input.Item["expiredAt"]; v == nil || aws.StringValue(v.N) != "1700086400" {
+				t.Errorf("expiredAt = %v, want now + TTL in epoch seconds", v)
+			}
+			if condition := writeCondition(t, fake); condition != "((attribute_not_exists (id)) OR (expiredAt < :0)) OR ((status = :1) AND (lockedUntil < :2))" {
+				t.Errorf("condition = %q", condition)
+			}
+		})
+	}
+}
+
+func TestIdempotencyCompleteAndAbort(t *testing.T) {
+	tests := []struct {
+		name      string
+		taken     bool
+		operation string
+		err       error
+	}{
+		{"complete", false, "UpdateItem", nil},
+		{"complete after a take over", true, "UpdateItem", ErrRequestTakenOver},
+		{"abort", false, "DeleteItem", nil},
+		{"abort after a take over", true, "DeleteItem", ErrRequestTakenOver},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				if tt.taken {
+					return fakeError{Code: dynamodb.ErrCodeConditionalCheckFailedException}
+				}
+				return nil
+			})
+			s := newTestIdempotencyStore(db)
+			var err error
+			if tt.operation == "UpdateItem" {
+				err = s.Complete(context.Background(), "k1", "t1", 201, []byte(`{"id":"p1"}`))
+			} else {
+				err = s.Abort(context.Background(), "k1", "t1")
+			}
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			requests := fake.Requests(tt.operation)
+			if len(requests) != 1 {
+				t.Fatalf("%d %s, want 1", len(requests), tt.operation)
+			}
+			if condition := writeCondition(t, fake); condition != "(status = :0) AND (token = :1)" {
+				t.Errorf("condition = %q, want the attempt to own the record", condition)
+			}
+			if tt.operation == "UpdateItem" {
+				var input dynamodb.UpdateItemInput
+				requests[0].Decode(t, &input)
+				if update := expand(aws.StringValue(input.UpdateExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues); update != "REMOVE lockedUntil\nSET status = completed, code = 201, expiredAt = 1700086400, response = {\"id\":\"p1\"}\n" {
+					t.Errorf("update = %q", update)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyDo(t *testing.T) {
+	failed := errors.New("payment failed")
+	tests := []struct {
+		name     string
+		existing map[string]*dynamodb.AttributeValue
+		fnErr    error
+		code     int
+		replayed bool
+		calls    int
+		id       string
+		writes   []string
+	}{
+		{"first request", nil, nil, 201, false, 1, "p1", []string{"PutItem", "UpdateItem"}},
+		{"replayed request", completedRecord("201", `{"id":"p0","amount":10}`), nil, 201, true, 0, "p0", []string{"PutItem"}},
+		{"failed request", nil, failed, 500, false, 1, "", []string{"PutItem", "DeleteItem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var completed *dynamodb.UpdateItemInput
+			db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				if r.Operation == "PutItem" && tt.existing != nil {
+					return fakeError{Code: dynamodb.ErrCodeConditionalCheckFailedException, Item: tt.existing}
+				}
+				if r.Operation == "UpdateItem" {
+					completed = &dynamodb.UpdateItemInput{}
+					r.Decode(t, completed)
+				}
+				return nil
+			})
+			calls := 0
+			var result payment
+			code, replayed, err := newTestIdempotencyStore(db).Do(context.Background(), "k1", &result, func(ctx context.Context) (int, interface{}, error) {
+				calls++
+				if tt.fnErr != nil {
+					return 500, nil, tt.fnErr
+				}
+				return 201, payment{Id: "p1", Amount: 10}, nil
+			})
+			if err != tt.fnErr {
+				t.Fatalf("err = %v, want %v", err, tt.fnErr)
+			}
+			if code != tt.code || replayed != tt.replayed || calls != tt.calls {
+				t.Errorf("Do = %d, %v after %d calls, want %d, %v after %d calls", code, replayed, calls, tt.code, tt.replayed, tt.calls)
+			}
+			if result.Id != tt.id {
+				t.Errorf("result = %+v, want id %q", result, tt.id)
+			}
+			var writes []string
+			for _, r := range fake.Requests("") {
+				writes = append(writes, r.Operation)
+			}
+			if len(writes) != len(tt.writes) {
+				t.Fatalf("requests = %v, want %v", writes, tt.writes)
+			}
+			for i := range writes {
+				if writes[i] != tt.writes[i] {
+					t.Errorf("requests = %v, want %v", writes, tt.writes)
+				}
+			}
+			if tt.replayed || tt.fnErr != nil {
+				return
+			}
+			if update := expand(aws.StringValue(completed.UpdateExpression), completed.ExpressionAttributeNames, completed.ExpressionAttributeValues); update != "REMOVE lockedUntil\nSET status = completed, code = 201, expiredAt = 1700086400, response = {\"id\":\"p1\",\"amount\":10}\n" {
+				t.Errorf("update = %q, want the status code and the response saved", update)
+			}
+		})
+	}
+}
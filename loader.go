@@ -2,9 +2,12 @@ package dynamodb
 
 import (
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"log"
 	"reflect"
+	"time"
 )
 
 type Loader struct {
@@ -14,6 +17,11 @@ type Loader struct {
 	partitionKey string
 	sortKey      string
 	Map          func(ctx context.Context, model interface{}) (interface{}, error)
+	SoftDelete   *SoftDelete
+	// TTLName is the time to live attribute, the items which are expired but not yet deleted by DynamoDB are not loaded.
+	TTLName string
+	// Segments is the number of parallel workers of All, 1 by default. With more than 1 segment, the items are not in the order of the table.
+	Segments int
 }
 
 func NewLoader(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, options ...func(context.Context, interface{}) (interface{}, error)) *Loader {
@@ -32,7 +40,11 @@ func NewLoader(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type,
 	if len(options) > 0 && options[0] != nil {
 		mp = options[0]
 	}
-	return &Loader{Database: db, tableName: tableName, modelType: modelType, partitionKey: partitionKey, sortKey: sortKey, Map: mp}
+	_, ttlName, err := GetTTLField(modelType)
+	if err != nil {
+		log.Println(modelType.Name() + " repository does not filter the expired items: " + err.Error())
+	}
+	return &Loader{Database: db, tableName: tableName, modelType: modelType, partitionKey: partitionKey, sortKey: sortKey, Map: mp, TTLName: ttlName, Segments: 1}
 }
 
 func (m *Loader) Keys() []string {
@@ -42,25 +54,29 @@ func (m *Loader) Keys() []string {
 	return []string{m.partitionKey}
 }
 
+// All reads all pages of the table, not only the first one, and returns the first error of Map instead of ignoring it.
 func (m *Loader) All(ctx context.Context) (interface{}, error) {
 	query, er1 := BuildQuery(m.tableName, SecondaryIndex{}, nil)
 	if er1 != nil {
 		return nil, er1
 	}
-	results, er2 := Find(ctx, m.Database, query, m.modelType)
-	if er2 != nil {
-		return results, er2
-	}
-	if results != nil && m.Map != nil {
-		return MapModels(ctx, results, m.Map)
-	}
-	return results, nil
+	ExcludeDeleted(query, m.SoftDelete)
+	ExcludeExpired(query, m.TTLName, time.Now())
+	return NewParallelScanner(m.Database, m.modelType, m.Segments, m.Map).ScanAll(ctx, *query)
 }
 
 func (m *Loader) Load(ctx context.Context, id interface{}) (interface{}, error) {
-	r, er1 := FindOne(ctx, m.Database, m.tableName, m.modelType, m.Keys(), id)
-	if er1 != nil {
-		return r, er1
+	return m.load(ctx, id, false)
+}
+
+func (m *Loader) LoadIncludingDeleted(ctx context.Context, id interface{}) (interface{}, error) {
+	return m.load(ctx, id, true)
+}
+
+func (m *Loader) load(ctx context.Context, id interface{}, includeDeleted bool) (interface{}, error) {
+	r := reflect.New(m.modelType).Interface()
+	if ok, er1 := m.findOne(ctx, id, r, includeDeleted); !ok {
+		return nil, er1
 	}
 	if m.Map != nil {
 		r2, er2 := m.Map(ctx, r)
@@ -69,11 +85,11 @@ func (m *Loader) Load(ctx context.Context, id interface{}) (interface{}, error)
 		}
 		return r2, er2
 	}
-	return r, er1
+	return r, nil
 }
 
 func (m *Loader) LoadAndDecode(ctx context.Context, id interface{}, result interface{}) (bool, error) {
-	ok, er1 := FindOneAndDecode(ctx, m.Database, m.tableName, m.Keys(), id, result)
+	ok, er1 := m.findOne(ctx, id, result, false)
 	if ok && er1 == nil && m.Map != nil {
 		_, er2 := m.Map(ctx, result)
 		if er2 != nil {
@@ -83,6 +99,55 @@ func (m *Loader) LoadAndDecode(ctx context.Context, id interface{}, result inter
 	return ok, er1
 }
 
+func (m *Loader) LoadMany(ctx context.Context, ids interface{}) (interface{}, error) {
+	items, er1 := GetItems(ctx, m.Database, m.tableName, m.Keys(), ids)
+	if er1 != nil {
+		return nil, er1
+	}
+	var existing []map[string]*dynamodb.AttributeValue
+	for _, item := range items {
+		if m.exists(item, false) {
+			existing = append(existing, item)
+		}
+	}
+	items = existing
+	results := reflect.New(reflect.SliceOf(m.modelType)).Interface()
+	if er2 := dynamodbattribute.UnmarshalListOfMaps(items, results); er2 != nil {
+		return results, er2
+	}
+	if m.Map != nil {
+		return MapModels(ctx, results, m.Map)
+	}
+	return results, nil
+}
+
 func (m *Loader) Exist(ctx context.Context, id interface{}) (bool, error) {
-	return Exist(ctx, m.Database, m.tableName, m.Keys(), id)
+	if m.SoftDelete == nil && len(m.TTLName) == 0 {
+		return Exist(ctx, m.Database, m.tableName, m.Keys(), id)
+	}
+	item, err := GetItem(ctx, m.Database, m.tableName, m.Keys(), id)
+	if err != nil {
+		return false, err
+	}
+	return m.exists(item, false), nil
+}
+
+func (m *Loader) findOne(ctx context.Context, id interface{}, result interface{}, includeDeleted bool) (bool, error) {
+	item, err := GetItem(ctx, m.Database, m.tableName, m.Keys(), id)
+	if err != nil {
+		return false, err
+	}
+	if !m.exists(item, includeDeleted) {
+		return false, fmt.Errorf("item not found")
+	}
+	err = dynamodbattribute.UnmarshalMap(item, result)
+	return true, err
+}
+
+// exists is false for an empty item, an expired item, or a soft deleted item unless includeDeleted.
+func (m *Loader) exists(item map[string]*dynamodb.AttributeValue, includeDeleted bool) bool {
+	if len(item) == 0 || IsExpired(item, m.TTLName, time.Now()) {
+		return false
+	}
+	return includeDeleted || m.SoftDelete == nil || !m.SoftDelete.IsDeleted(item)
 }
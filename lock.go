@@ -0,0 +1,302 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// minBackoff is the smallest wait of Acquire, so that a zero MinBackoff or MaxBackoff does not retry in a busy loop.
+const minBackoff = 10 * time.Millisecond
+
+var (
+	ErrLockHeld = errors.New("lock is held by another owner")
+	ErrLockLost = errors.New("lock is lost")
+)
+
+// LockClient acquires leases on the items of a lock table. The lease expiry is compared with the clock of the hosts, so Lease must be much larger than their clock skew.
+type LockClient struct {
+	Database  *dynamodb.DynamoDB
+	tableName string
+	keyName   string
+	ownerName string
+	leaseName string
+	tokenName string
+	Owner     string
+	Lease     time.Duration
+	// Heartbeat is the renewal interval of the lease, the default is a third of Lease; a negative value disables the renewal.
+	Heartbeat  time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Now        func() time.Time
+}
+
+// NewLockClient creates a lock client; options are the names of the key, owner, lease (epoch milliseconds) and fencing token attributes.
+func NewLockClient(db *dynamodb.DynamoDB, tableName string, owner string, lease time.Duration, options ...string) *LockClient {
+	names := []string{"id", "owner", "leaseUntil", "token"}
+	for i := 0; i < len(options) && i < len(names); i++ {
+		if len(options[i]) > 0 {
+			names[i] = options[i]
+		}
+	}
+	return &LockClient{Database: db, tableName: tableName, keyName: names[0], ownerName: names[1], leaseName: names[2], tokenName: names[3], Owner: owner, Lease: lease, MinBackoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+}
+
+func (c *LockClient) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *LockClient) heartbeat() time.Duration {
+	if c.Heartbeat == 0 {
+		return c.Lease / 3
+	}
+	return c.Heartbeat
+}
+
+func (c *LockClient) keyMap(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{c.keyName: {S: aws.String(key)}}
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// AcquireLease takes the lease of the key for the owner if it is free or expired, it returns ErrLockHeld otherwise.
+// Each acquisition increments the fencing token, which is kept when the lease is released.
+func (c *LockClient) AcquireLease(ctx context.Context, key string, owner string, lease time.Duration) (int64, error) {
+	update, free := c.acquireExpression(owner, c.now(), lease)
+	var result map[string]interface{}
+	_, err := updateItem(ctx, c.Database, c.tableName, c.keyMap(key), update, &free, conditionFailed, WriteOptions{ReturnValues: dynamodb.ReturnValueAllNew, Result: &result})
+	if err != nil {
+		if _, ok := err.(*ConditionalCheckFailedError); ok {
+			return 0, ErrLockHeld
+		}
+		return 0, err
+	}
+	token, _ := result[c.tokenName].(float64)
+	return int64(token), nil
+}
+
+// acquireExpression takes the lease and increments the token, on condition that the lease is free or expired.
+func (c *LockClient) acquireExpression(owner string, now time.Time, lease time.Duration) (expression.UpdateBuilder, expression.ConditionBuilder) {
+	leaseName := expression.Name(c.leaseName)
+	free := expression.AttributeNotExists(expression.Name(c.ownerName)).Or(leaseName.LessThan(expression.Value(toMillis(now))))
+	update := expression.Set(expression.Name(c.ownerName), expression.Value(owner)).
+		Set(leaseName, expression.Value(toMillis(now.Add(lease)))).
+		Add(expression.Name(c.tokenName), expression.Value(1))
+	return update, free
+}
+
+// RenewLease extends the lease of the owner, it returns ErrLockLost if the owner does not hold it with this token anymore.
+func (c *LockClient) RenewLease(ctx context.Context, key string, owner string, token int64, lease time.Duration) error {
+	held := c.held(owner, token)
+	update := expression.Set(expression.Name(c.leaseName), expression.Value(toMillis(c.now().Add(lease))))
+	_, err := updateItem(ctx, c.Database, c.tableName, c.keyMap(key), update, &held, conditionFailed, WriteOptions{})
+	if _, ok := err.(*ConditionalCheckFailedError); ok {
+		return ErrLockLost
+	}
+	return err
+}
+
+// ReleaseLease frees the lease of the owner, it returns ErrLockLost if the owner does not hold it with this token anymore.
+func (c *LockClient) ReleaseLease(ctx context.Context, key string, owner string, token int64) error {
+	held := c.held(owner, token)
+	update := expression.Remove(expression.Name(c.ownerName)).Set(expression.Name(c.leaseName), expression.Value(0))
+	_, err := updateItem(ctx, c.Database, c.tableName, c.keyMap(key), update, &held, conditionFailed, WriteOptions{})
+	if _, ok := err.(*ConditionalCheckFailedError); ok {
+		return ErrLockLost
+	}
+	return err
+}
+
+// GetLease returns the owner of the lease and its expiry; the owner is empty if the lease is free or expired.
+func (c *LockClient) GetLease(ctx context.Context, key string) (string, time.Time, error) {
+	output, err := c.Database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.tableName),
+		Key:            c.keyMap(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || len(output.Item) == 0 {
+		return "", time.Time{}, err
+	}
+	var leaseUntil time.Time
+	if v, ok := output.Item[c.leaseName]; ok && v.N != nil {
+		if ms, er2 := strconv.ParseInt(*v.N, 10, 64); er2 == nil {
+			leaseUntil = time.Unix(0, ms*int64(time.Millisecond))
+		}
+	}
+	owner := ""
+	if v, ok := output.Item[c.ownerName]; ok && v.S != nil && leaseUntil.After(c.now()) {
+		owner = *v.S
+	}
+	return owner, leaseUntil, nil
+}
+
+func (c *LockClient) held(owner string, token int64) expression.ConditionBuilder {
+	return expression.Name(c.ownerName).Equal(expression.Value(owner)).
+		And(expression.Name(c.tokenName).Equal(expression.Value(token)))
+}
+
+// TryAcquire acquires the lock if it is free or its lease has expired, it returns ErrLockHeld otherwise.
+func (c *LockClient) TryAcquire(ctx context.Context, key string) (*Lock, error) {
+	leaseUntil := c.now().Add(c.Lease)
+	token, err := c.AcquireLease(ctx, key, c.Owner, c.Lease)
+	if err != nil {
+		return nil, err
+	}
+	lock := &Lock{client: c, Key: key, Owner: c.Owner, Token: token, leaseUntil: leaseUntil, lost: make(chan struct{})}
+	if c.heartbeat() > 0 {
+		lock.stop = make(chan struct{})
+		lock.done = make(chan struct{})
+		go lock.renew()
+	}
+	return lock, nil
+}
+
+// Acquire waits until the lock is acquired or the context is done, retrying with an exponential backoff.
+func (c *LockClient) Acquire(ctx context.Context, key string) (*Lock, error) {
+	backoff := c.nextBackoff(0)
+	for {
+		lock, err := c.TryAcquire(ctx, key)
+		if err != ErrLockHeld {
+			return lock, err
+		}
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = c.nextBackoff(backoff)
+	}
+}
+
+// nextBackoff doubles the backoff between MinBackoff and MaxBackoff, neither of them being less than minBackoff.
+func (c *LockClient) nextBackoff(backoff time.Duration) time.Duration {
+	low, high := c.MinBackoff, c.MaxBackoff
+	if low < minBackoff {
+		low = minBackoff
+	}
+	if high < low {
+		high = low
+	}
+	if backoff *= 2; backoff < low {
+		return low
+	}
+	if backoff > high {
+		return high
+	}
+	return backoff
+}
+
+type Lock struct {
+	client *LockClient
+	Key    string
+	Owner  string
+	// Token is the fencing token: it increases with each acquisition, so a resource can reject the writes of an older holder.
+	Token      int64
+	mu         sync.Mutex
+	leaseUntil time.Time
+	lost       chan struct{}
+	lostOnce   sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+	closeErr   error
+}
+
+func (l *Lock) LeaseUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leaseUntil
+}
+
+// Lost is closed when the lease could not be renewed before its expiry or the lock has been taken by another owner.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *Lock) markLost() {
+	l.lostOnce.Do(func() { close(l.lost) })
+}
+
+func (l *Lock) isLost() bool {
+	select {
+	case <-l.lost:
+		return true
+	default:
+		return false
+	}
+}
+
+// Renew extends the lease, it returns ErrLockLost if the lock is not held anymore.
+func (l *Lock) Renew(ctx context.Context) error {
+	if l.isLost() {
+		return ErrLockLost
+	}
+	c := l.client
+	leaseUntil := c.now().Add(c.Lease)
+	if err := c.RenewLease(ctx, l.Key, l.Owner, l.Token, c.Lease); err != nil {
+		if err == ErrLockLost {
+			l.markLost()
+		}
+		return err
+	}
+	l.mu.Lock()
+	l.leaseUntil = leaseUntil
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *Lock) renew() {
+	defer close(l.done)
+	interval := l.client.heartbeat()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := l.Renew(ctx)
+			cancel()
+			if err == ErrLockLost {
+				return
+			}
+			if err != nil && !l.client.now().Before(l.LeaseUntil()) {
+				l.markLost()
+				return
+			}
+		}
+	}
+}
+
+// Close stops the renewal and releases the lock, waiting at most the lease duration; it returns ErrLockLost if the lock was not held anymore.
+func (l *Lock) Close() error {
+	l.closeOnce.Do(func() {
+		if l.stop != nil {
+			close(l.stop)
+			<-l.done
+		}
+		if l.isLost() {
+			l.closeErr = ErrLockLost
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), l.client.Lease)
+		err := l.client.ReleaseLease(ctx, l.Key, l.Owner, l.Token)
+		cancel()
+		l.markLost()
+		l.closeErr = err
+	})
+	return l.closeErr
+}
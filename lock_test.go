@@ -0,0 +1,100 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"testing"
+	"time"
+)
+
+func TestLockBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max time.Duration
+		backoff  time.Duration
+		want     time.Duration
+	}{
+		{"first", 100 * time.Millisecond, 5 * time.Second, 0, 100 * time.Millisecond},
+		{"doubled", 100 * time.Millisecond, 5 * time.Second, 100 * time.Millisecond, 200 * time.Millisecond},
+		{"capped", 100 * time.Millisecond, 5 * time.Second, 4 * time.Second, 5 * time.Second},
+		{"zero min", 0, 5 * time.Second, 0, minBackoff},
+		{"zero min and max", 0, 0, minBackoff, minBackoff},
+		{"negative min", -time.Second, 0, 0, minBackoff},
+		{"max less than min", time.Second, 100 * time.Millisecond, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &LockClient{MinBackoff: tt.min, MaxBackoff: tt.max}
+			if got := c.nextBackoff(tt.backoff); got != tt.want {
+				t.Errorf("nextBackoff(%v) = %v, want %v", tt.backoff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockExpressions(t *testing.T) {
+	c := NewLockClient(nil, "locks", "node-1", 30*time.Second)
+	now := time.Unix(1700000000, 0)
+	update, free := c.acquireExpression("node-1", now, 30*time.Second)
+	expr, err := expression.NewBuilder().WithCondition(free).WithUpdate(update).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := expr.Names()
+	values := expr.Values()
+	if got := aws.StringValue(expr.Condition()); got != "(attribute_not_exists (#0)) OR (#1 < :0)" {
+		t.Errorf("acquire condition = %q", got)
+	}
+	if aws.StringValue(names["#0"]) != "owner" || aws.StringValue(names["#1"]) != "leaseUntil" {
+		t.Errorf("acquire names = %v", names)
+	}
+	if got := aws.StringValue(values[":0"].N); got != "1700000000000" {
+		t.Errorf("acquire now = %s, want epoch milliseconds", got)
+	}
+	if got := aws.StringValue(expr.Update()); got != "ADD #2 :1\nSET #0 = :2, #1 = :3\n" {
+		t.Errorf("acquire update = %q", got)
+	}
+	if aws.StringValue(names["#2"]) != "token" || aws.StringValue(values[":1"].N) != "1" || aws.StringValue(values[":3"].N) != "1700000030000" {
+		t.Errorf("acquire update names = %v, values = %v", names, values)
+	}
+
+	expr, err = expression.NewBuilder().WithCondition(c.held("node-1", 7)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(expr.Condition()); got != "(#0 = :0) AND (#1 = :1)" {
+		t.Errorf("held condition = %q", got)
+	}
+	if aws.StringValue(expr.Values()[":0"].S) != "node-1" || aws.StringValue(expr.Values()[":1"].N) != "7" {
+		t.Errorf("held values = %v", expr.Values())
+	}
+}
+
+func TestLockCloseTimeout(t *testing.T) {
+	var fake *fakeDynamoDB
+	db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		if len(fake.Requests("UpdateItem")) == 1 {
+			return &dynamodb.UpdateItemOutput{Attributes: map[string]*dynamodb.AttributeValue{"token": {N: aws.String("1")}}}
+		}
+		time.Sleep(300 * time.Millisecond)
+		return nil
+	})
+	c := NewLockClient(db, "locks", "node-1", 30*time.Millisecond)
+	c.Heartbeat = -1
+	lock, err := c.TryAcquire(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Token != 1 {
+		t.Errorf("token = %d, want 1", lock.Token)
+	}
+	start := time.Now()
+	if err = lock.Close(); err == nil {
+		t.Error("Close returns no error when the release times out")
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Close returns after %v, want at most the lease", elapsed)
+	}
+}
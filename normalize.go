@@ -0,0 +1,120 @@
+package dynamodb
+
+import (
+	"reflect"
+	"strings"
+)
+
+var accents = map[rune]rune{}
+
+func init() {
+	groups := map[rune]string{
+		'a': "àáâãäåāăąạảấầẩẫậắằẳẵặ",
+		'c': "çćĉċč",
+		'd': "ďđ",
+		'e': "èéêëēĕėęěẹẻẽếềểễệ",
+		'g': "ĝğġģ",
+		'h': "ĥħ",
+		'i': "ìíîïĩīĭįıỉị",
+		'j': "ĵ",
+		'k': "ķ",
+		'l': "ĺļľŀł",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏőơọỏốồổỗộớờởỡợ",
+		'r': "ŕŗř",
+		's': "śŝşšș",
+		't': "ţťŧț",
+		'u': "ùúûüũūŭůűųưụủứừửữự",
+		'w': "ŵ",
+		'y': "ýÿŷỳỵỷỹ",
+		'z': "źżž",
+	}
+	for base, letters := range groups {
+		for _, r := range letters {
+			accents[r] = base
+		}
+	}
+}
+
+// Normalize lowercases the text, strips the accents of the latin letters and collapses the whitespaces.
+func Normalize(s string) string {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	return strings.Map(func(r rune) rune {
+		if base, ok := accents[r]; ok {
+			return base
+		}
+		return r
+	}, s)
+}
+
+// GetNormalizedName returns the shadow attribute of a field tagged normalize, which stores the normalized value of the field.
+// The tag value is the name of the shadow attribute, normalize:"true" names it after the attribute with the suffix Normalized.
+func GetNormalizedName(field reflect.StructField, name string) (string, bool) {
+	tag, ok := field.Tag.Lookup("normalize")
+	if !ok || tag == "false" || tag == "-" {
+		return "", false
+	}
+	if len(tag) == 0 || tag == "true" {
+		return name + "Normalized", true
+	}
+	return tag, true
+}
+
+// GetNormalizedFields returns the shadow attributes of the attributes of the model.
+func GetNormalizedFields(modelType reflect.Type) map[string]string {
+	fields := make(map[string]string)
+	for i := 0; i < modelType.NumField(); i++ {
+		_, name, _ := GetFieldByIndex(modelType, i)
+		if shadow, ok := GetNormalizedName(modelType.Field(i), name); ok {
+			fields[name] = shadow
+		}
+	}
+	return fields
+}
+
+// normalizedAttributes returns the shadow attributes of the item. A nil, empty or non string value gives a nil shadow attribute,
+// which is removed by an update and not written by a put, because the shadow attribute may be the key of an index.
+func normalizedAttributes(normalized map[string]string, item map[string]interface{}) map[string]interface{} {
+	if len(normalized) == 0 {
+		return nil
+	}
+	attributes := make(map[string]interface{})
+	for name, shadow := range normalized {
+		v, ok := item[name]
+		if !ok {
+			continue
+		}
+		var normalized string
+		if s, ok := v.(string); ok {
+			normalized = Normalize(s)
+		} else if s, ok := v.(*string); ok && s != nil {
+			normalized = Normalize(*s)
+		}
+		if len(normalized) > 0 {
+			attributes[shadow] = normalized
+		} else {
+			attributes[shadow] = nil
+		}
+	}
+	return attributes
+}
+
+// normalizeModel returns the shadow attributes of a struct model.
+func normalizeModel(normalized map[string]string, model interface{}) map[string]interface{} {
+	if len(normalized) == 0 {
+		return nil
+	}
+	value := reflect.Indirect(reflect.ValueOf(model))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	item := make(map[string]interface{})
+	for i := 0; i < value.NumField(); i++ {
+		if _, name, ok := GetFieldByIndex(value.Type(), i); ok {
+			if _, ok := normalized[name]; ok {
+				item[name] = value.Field(i).Interface()
+			}
+		}
+	}
+	return normalizedAttributes(normalized, item)
+}
@@ -0,0 +1,52 @@
+package dynamodb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"", ""},
+		{"Hello", "hello"},
+		{"  Crème   Brûlée ", "creme brulee"},
+		{"Nguyễn Văn Đức", "nguyen van duc"},
+		{"ŁÓDŹ", "lodz"},
+		{"日本", "日本"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.s); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizedAttributes(t *testing.T) {
+	normalized := map[string]string{"name": "nameNormalized"}
+	name := "Crème"
+	empty := ""
+	var none *string
+	tests := []struct {
+		name string
+		item map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"string", map[string]interface{}{"name": "Crème"}, map[string]interface{}{"nameNormalized": "creme"}},
+		{"pointer", map[string]interface{}{"name": &name}, map[string]interface{}{"nameNormalized": "creme"}},
+		{"nil pointer", map[string]interface{}{"name": none}, map[string]interface{}{"nameNormalized": nil}},
+		{"nil", map[string]interface{}{"name": nil}, map[string]interface{}{"nameNormalized": nil}},
+		{"empty", map[string]interface{}{"name": "  "}, map[string]interface{}{"nameNormalized": nil}},
+		{"empty pointer", map[string]interface{}{"name": &empty}, map[string]interface{}{"nameNormalized": nil}},
+		{"not a string", map[string]interface{}{"name": 1}, map[string]interface{}{"nameNormalized": nil}},
+		{"missing", map[string]interface{}{"age": 1}, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizedAttributes(normalized, tt.item); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizedAttributes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	// OutboxPendingIndex is the default index of the relay: a global secondary index with the partition key "pending" and the sort key "id".
+	// It is sparse because only the pending messages have the "pending" attribute.
+	OutboxPendingIndex = "pending-index"
+	// MaxTransactItems is the maximum number of items of TransactWriteItems, the written item included.
+	MaxTransactItems = 100
+)
+
+// OutboxMessage is an item of the outbox table, its key is AggregateId and Id; Id is ordered by creation time within an aggregate.
+type OutboxMessage struct {
+	AggregateId   string    `json:"aggregateId" dynamodbav:"aggregateId"`
+	Id            string    `json:"id" dynamodbav:"id"`
+	Type          string    `json:"type,omitempty" dynamodbav:"type,omitempty"`
+	Payload       []byte    `json:"payload,omitempty" dynamodbav:"payload,omitempty"`
+	Status        string    `json:"status" dynamodbav:"status"`
+	Pending       string    `json:"pending,omitempty" dynamodbav:"pending,omitempty"`
+	Attempts      int       `json:"attempts" dynamodbav:"attempts"`
+	NextAttemptAt int64     `json:"nextAttemptAt" dynamodbav:"nextAttemptAt"`
+	CreatedAt     time.Time `json:"createdAt" dynamodbav:"createdAt"`
+	ExpiredAt     TTL       `json:"expiredAt,omitempty" dynamodbav:"expiredAt,omitempty"`
+}
+
+// NewOutboxMessage creates a message with the JSON of the payload.
+func NewOutboxMessage(aggregateId string, messageType string, payload interface{}) (OutboxMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	return OutboxMessage{AggregateId: aggregateId, Type: messageType, Payload: data}, nil
+}
+
+type Outbox struct {
+	TableName string
+	// Retention is the time to live of the sent messages in the expiredAt attribute, 0 keeps them.
+	Retention time.Duration
+	Now       func() time.Time
+}
+
+func NewOutbox(tableName string, retention time.Duration) *Outbox {
+	return &Outbox{TableName: tableName, Retention: retention}
+}
+
+func (o *Outbox) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// writeItems builds the puts of the messages; the messages of a write keep their order because their ids use increasing times.
+func (o *Outbox) writeItems(messages []OutboxMessage) ([]*dynamodb.TransactWriteItem, error) {
+	now := o.now()
+	items := make([]*dynamodb.TransactWriteItem, 0, len(messages))
+	for i, message := range messages {
+		if len(message.AggregateId) == 0 {
+			return nil, fmt.Errorf("aggregateId of outbox message is required")
+		}
+		if len(message.Id) == 0 {
+			b := make([]byte, 4)
+			if _, err := rand.Read(b); err != nil {
+				return nil, err
+			}
+			message.Id = fmt.Sprintf("%019d-%s", now.UnixNano()+int64(i), hex.EncodeToString(b))
+		}
+		message.Status = OutboxPending
+		message.Pending = OutboxPending
+		if message.CreatedAt.IsZero() {
+			message.CreatedAt = now
+		}
+		item, err := dynamodbattribute.MarshalMap(message)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+			TableName:                aws.String(o.TableName),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+			ExpressionAttributeNames: map[string]*string{"#id": aws.String("id")},
+		}})
+	}
+	return items, nil
+}
+
+// transactWrite writes the item and the outbox messages of the options in one transaction.
+func transactWrite(ctx context.Context, db *dynamodb.DynamoDB, item *dynamodb.TransactWriteItem, message func(map[string]*dynamodb.AttributeValue) string, options WriteOptions) (int64, error) {
+	if options.Outbox == nil {
+		return 0, fmt.Errorf("outbox is required to write outbox messages")
+	}
+	if len(options.ReturnValues) > 0 {
+		return 0, fmt.Errorf("return values are not supported with outbox messages")
+	}
+	if len(options.Messages)+1 > MaxTransactItems {
+		return 0, fmt.Errorf("%d outbox messages cannot be written with the item, a transaction has at most %d items", len(options.Messages), MaxTransactItems)
+	}
+	messages, err := options.Outbox.writeItems(options.Messages)
+	if err != nil {
+		return 0, err
+	}
+	output, err := db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:          append([]*dynamodb.TransactWriteItem{item}, messages...),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		if e, ok := err.(*dynamodb.TransactionCanceledException); ok && len(e.CancellationReasons) > 0 {
+			if reason := e.CancellationReasons[0]; aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+				ce := &ConditionalCheckFailedError{Message: message(reason.Item)}
+				if options.ReturnValuesOnConditionCheckFailure {
+					ce.Item = reason.Item
+				}
+				return 0, ce
+			}
+		}
+		return 0, err
+	}
+	var units int64
+	for _, capacity := range output.ConsumedCapacity {
+		units += capacityUnits(capacity)
+	}
+	return units, nil
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, message OutboxMessage) error
+}
+
+// Relay publishes the pending messages of the outbox, in order within each aggregate.
+// Delivery is at least once: a message is published again if it cannot be marked as sent.
+type Relay struct {
+	Database  *dynamodb.DynamoDB
+	Outbox    *Outbox
+	Publisher Publisher
+	// IndexName is the sparse index of the pending messages, see OutboxPendingIndex; if it is empty, each poll scans the whole table.
+	IndexName  string
+	Interval   time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func NewRelay(db *dynamodb.DynamoDB, outbox *Outbox, publisher Publisher, interval time.Duration) *Relay {
+	return &Relay{Database: db, Outbox: outbox, Publisher: publisher, IndexName: OutboxPendingIndex, Interval: interval, MinBackoff: time.Second, MaxBackoff: 5 * time.Minute}
+}
+
+// Run relays the pending messages every Interval until the context is cancelled.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		// The messages which were not sent are retried at the next poll.
+		if _, err := r.RelayPending(ctx); err != nil && ctx.Err() == nil {
+			log.Println(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.Interval):
+		}
+	}
+}
+
+// RelayPending publishes the pending messages once and returns the number of sent messages.
+// The messages of an aggregate after a failed or delayed one are kept for the next poll.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	messages, err := r.pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	aggregates := make(map[string][]OutboxMessage)
+	var ids []string
+	for _, message := range messages {
+		if _, ok := aggregates[message.AggregateId]; !ok {
+			ids = append(ids, message.AggregateId)
+		}
+		aggregates[message.AggregateId] = append(aggregates[message.AggregateId], message)
+	}
+	sent := 0
+	for _, id := range ids {
+		list := aggregates[id]
+		sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+		for _, message := range list {
+			if ctx.Err() != nil {
+				return sent, ctx.Err()
+			}
+			if message.NextAttemptAt > toMillis(r.Outbox.now()) {
+				break
+			}
+			if er1 := r.Publisher.Publish(ctx, message); er1 != nil {
+				if er2 := r.retryLater(ctx, message); er2 != nil {
+					return sent, er2
+				}
+				break
+			}
+			if er3 := r.markSent(ctx, message); er3 != nil {
+				return sent, er3
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// pending queries the pending index, or scans the table if there is no index.
+func (r *Relay) pending(ctx context.Context) ([]OutboxMessage, error) {
+	builder := expression.NewBuilder().WithFilter(expression.Name("status").Equal(expression.Value(OutboxPending)))
+	if len(r.IndexName) > 0 {
+		builder = builder.WithKeyCondition(expression.Key("pending").Equal(expression.Value(OutboxPending)))
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	var messages []OutboxMessage
+	var er2 error
+	read := func(items []map[string]*dynamodb.AttributeValue) bool {
+		var list []OutboxMessage
+		if er2 = dynamodbattribute.UnmarshalListOfMaps(items, &list); er2 != nil {
+			return false
+		}
+		messages = append(messages, list...)
+		return true
+	}
+	if len(r.IndexName) > 0 {
+		err = r.Database.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(r.Outbox.TableName),
+			IndexName:                 aws.String(r.IndexName),
+			KeyConditionExpression:    expr.KeyCondition(),
+			FilterExpression:          expr.Filter(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+			return read(page.Items)
+		})
+	} else {
+		err = r.Database.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(r.Outbox.TableName),
+			FilterExpression:          expr.Filter(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ConsistentRead:            aws.Bool(true),
+		}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+			return read(page.Items)
+		})
+	}
+	if err != nil {
+		return messages, err
+	}
+	return messages, er2
+}
+
+func (r *Relay) keyMap(message OutboxMessage) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{"aggregateId": {S: aws.String(message.AggregateId)}, "id": {S: aws.String(message.Id)}}
+}
+
+// markSent ignores a message which is not pending anymore, it has been sent by another relay.
+// It removes the pending attribute, so that the message leaves the pending index.
+func (r *Relay) markSent(ctx context.Context, message OutboxMessage) error {
+	update := expression.Set(expression.Name("status"), expression.Value(OutboxSent)).Remove(expression.Name("pending"))
+	if r.Outbox.Retention > 0 {
+		update = update.Set(expression.Name("expiredAt"), expression.Value(r.Outbox.now().Add(r.Outbox.Retention).Unix()))
+	}
+	pending := expression.Name("status").Equal(expression.Value(OutboxPending))
+	_, err := updateItem(ctx, r.Database, r.Outbox.TableName, r.keyMap(message), update, &pending, conditionFailed, WriteOptions{})
+	if IsConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *Relay) retryLater(ctx context.Context, message OutboxMessage) error {
+	backoff := r.MinBackoff
+	for i := 0; i < message.Attempts && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	update := expression.Set(expression.Name("attempts"), expression.Value(message.Attempts+1)).
+		Set(expression.Name("nextAttemptAt"), expression.Value(toMillis(r.Outbox.now().Add(backoff))))
+	unchanged := expression.Name("status").Equal(expression.Value(OutboxPending)).And(expression.Name("attempts").Equal(expression.Value(message.Attempts)))
+	_, err := updateItem(ctx, r.Database, r.Outbox.TableName, r.keyMap(message), update, &unchanged, conditionFailed, WriteOptions{})
+	if IsConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
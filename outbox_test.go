@@ -0,0 +1,196 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func outboxWriter(db *dynamodb.DynamoDB) *Writer {
+	w := NewWriter(db, "accounts", reflect.TypeOf(account{}), "Id", "")
+	w.Outbox = NewOutbox("outbox", time.Hour)
+	w.Outbox.Now = func() time.Time {
+		return time.Unix(1700000000, 0)
+	}
+	return w
+}
+
+func messages(n int) []OutboxMessage {
+	var list []OutboxMessage
+	for i := 0; i < n; i++ {
+		list = append(list, OutboxMessage{AggregateId: "a1", Type: "AccountOpened"})
+	}
+	return list
+}
+
+func TestOutboxWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		options WriteOptions
+		items   int
+		err     bool
+	}{
+		{"item and messages", WriteOptions{Messages: messages(2)}, 3, false},
+		{"largest transaction", WriteOptions{Messages: messages(MaxTransactItems - 1)}, MaxTransactItems, false},
+		{"too many messages", WriteOptions{Messages: messages(MaxTransactItems)}, 0, true},
+		{"return values", WriteOptions{Messages: messages(1), ReturnValues: dynamodb.ReturnValueAllOld, Result: &account{}}, 0, true},
+		{"message without aggregate", WriteOptions{Messages: []OutboxMessage{{Type: "AccountOpened"}}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, nil)
+			_, err := outboxWriter(db).InsertWithOptions(context.Background(), &account{Id: "a1"}, tt.options)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			requests := fake.Requests("")
+			if tt.err {
+				if len(requests) > 0 {
+					t.Errorf("%d requests, want none", len(requests))
+				}
+				return
+			}
+			if len(requests) != 1 || requests[0].Operation != "TransactWriteItems" {
+				t.Fatalf("requests = %v, want one TransactWriteItems", requests)
+			}
+			var input dynamodb.TransactWriteItemsInput
+			requests[0].Decode(t, &input)
+			if len(input.TransactItems) != tt.items {
+				t.Fatalf("%d items, want %d", len(input.TransactItems), tt.items)
+			}
+			if put := input.TransactItems[0].Put; put == nil || aws.StringValue(put.TableName) != "accounts" || aws.StringValue(put.ConditionExpression) != "attribute_not_exists (#0)" {
+				t.Errorf("first item = %v, want the insert of the account", input.TransactItems[0])
+			}
+			previous := ""
+			for _, item := range input.TransactItems[1:] {
+				var message OutboxMessage
+				if err := dynamodbattribute.UnmarshalMap(item.Put.Item, &message); err != nil {
+					t.Fatal(err)
+				}
+				if message.Status != OutboxPending || message.Pending != OutboxPending || aws.StringValue(item.Put.TableName) != "outbox" {
+					t.Errorf("message = %+v, want a pending message of the outbox table", message)
+				}
+				if message.Id <= previous {
+					t.Errorf("message id %q is not after %q", message.Id, previous)
+				}
+				previous = message.Id
+			}
+		})
+	}
+}
+
+func TestOutboxWriteConditionFailed(t *testing.T) {
+	stored := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a1")}}
+	db, _ := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		return fakeError{Code: dynamodb.ErrCodeTransactionCanceledException, Reasons: []*dynamodb.CancellationReason{
+			{Code: aws.String("ConditionalCheckFailed"), Item: stored},
+			{Code: aws.String("None")},
+		}}
+	})
+	_, err := outboxWriter(db).InsertWithOptions(context.Background(), &account{Id: "a1"}, WriteOptions{Messages: messages(1)})
+	if e, ok := err.(*ConditionalCheckFailedError); !ok || e.Message != ObjectExist {
+		t.Errorf("err = %v, want %q", err, ObjectExist)
+	}
+}
+
+type fakePublisher struct {
+	failed    map[string]bool
+	published []string
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, message OutboxMessage) error {
+	if p.failed[message.Id] {
+		return errors.New("broker is down")
+	}
+	p.published = append(p.published, message.Id)
+	return nil
+}
+
+func pendingMessage(aggregateId string, id string, nextAttemptAt int64) map[string]*dynamodb.AttributeValue {
+	item, _ := dynamodbattribute.MarshalMap(OutboxMessage{AggregateId: aggregateId, Id: id, Status: OutboxPending, Pending: OutboxPending, NextAttemptAt: nextAttemptAt})
+	return item
+}
+
+func TestRelayPending(t *testing.T) {
+	tests := []struct {
+		name      string
+		indexName string
+		operation string
+	}{
+		{"sparse index", OutboxPendingIndex, "Query"},
+		{"scan without index", "", "Scan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			items := []map[string]*dynamodb.AttributeValue{
+				pendingMessage("a1", "2", 0),
+				pendingMessage("a2", "3", 0),
+				pendingMessage("a1", "1", 0),
+				pendingMessage("a2", "4", 0),
+				pendingMessage("a3", "5", toMillis(now.Add(time.Minute))),
+				pendingMessage("a3", "6", 0),
+			}
+			db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				switch r.Operation {
+				case "Query":
+					return &dynamodb.QueryOutput{Items: items}
+				case "Scan":
+					return &dynamodb.ScanOutput{Items: items}
+				}
+				return nil
+			})
+			outbox := NewOutbox("outbox", time.Hour)
+			outbox.Now = func() time.Time {
+				return now
+			}
+			publisher := &fakePublisher{failed: map[string]bool{"3": true}}
+			relay := NewRelay(db, outbox, publisher, time.Second)
+			relay.IndexName = tt.indexName
+			sent, err := relay.RelayPending(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a2 stops at its failed message, a3 waits for its delayed message.
+			if want := []string{"1", "2"}; sent != 2 || !reflect.DeepEqual(publisher.published, want) {
+				t.Errorf("sent %d messages %v, want %v", sent, publisher.published, want)
+			}
+			reads := fake.Requests(tt.operation)
+			if len(reads) != 1 {
+				t.Fatalf("%d %s, want 1", len(reads), tt.operation)
+			}
+			if tt.operation == "Query" {
+				var input dynamodb.QueryInput
+				reads[0].Decode(t, &input)
+				if aws.StringValue(input.IndexName) != OutboxPendingIndex || expand(aws.StringValue(input.KeyConditionExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues) != "pending = pending" {
+					t.Errorf("query = %v, want the pending index", input)
+				}
+			} else {
+				var input dynamodb.ScanInput
+				reads[0].Decode(t, &input)
+				if !aws.BoolValue(input.ConsistentRead) {
+					t.Error("the scan of the table is not consistent")
+				}
+			}
+			var updates []string
+			for _, r := range fake.Requests("UpdateItem") {
+				var input dynamodb.UpdateItemInput
+				r.Decode(t, &input)
+				updates = append(updates, aws.StringValue(input.Key["id"].S)+": "+expand(aws.StringValue(input.UpdateExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+			}
+			want := []string{
+				"1: REMOVE pending\nSET status = sent, expiredAt = 1700003600\n",
+				"2: REMOVE pending\nSET status = sent, expiredAt = 1700003600\n",
+				"3: SET attempts = 1, nextAttemptAt = 1700000001000\n",
+			}
+			if !reflect.DeepEqual(updates, want) {
+				t.Errorf("updates = %q, want %q", updates, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var ErrUnknownStartKey = errors.New("cannot build the start key of the next page without the key attributes")
+
+// ReadPage reads the items of the query from its ExclusiveStartKey until it has offset + limit matching items or the end of the results,
+// because DynamoDB applies Limit before the filter. It returns limit items after offset, or all items if limit <= 0,
+// and the start key of the next page, or nil at the end. The items on the boundaries do not match.
+// The requests read full pages; if the last page has more items than needed, the next page starts after the last returned item,
+// whose key attributes are those of the LastEvaluatedKey, or of the table description on the last page.
+func ReadPage(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.QueryInput, offset int64, limit int64, boundaries ...Boundary) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	read := func(query dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+		if query.KeyConditionExpression == nil {
+			scan := ToScanInput(&query)
+			output, err := db.ScanWithContext(ctx, &scan)
+			if err != nil {
+				return nil, nil, err
+			}
+			return output.Items, output.LastEvaluatedKey, nil
+		}
+		output, err := db.QueryWithContext(ctx, &query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ExcludeBoundaries(output.Items, boundaries), output.LastEvaluatedKey, nil
+	}
+	keys := func() ([]string, error) {
+		return GetKeyNames(ctx, db, aws.StringValue(query.TableName), aws.StringValue(query.IndexName))
+	}
+	return readPage(query, offset, limit, read, keys)
+}
+
+func readPage(query dynamodb.QueryInput, offset int64, limit int64, read func(dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error), keys func() ([]string, error)) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	need := offset + limit
+	var items []map[string]*dynamodb.AttributeValue
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	for {
+		page, lastKey, err := read(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		items, lastEvaluatedKey = append(items, page...), lastKey
+		if limit > 0 && int64(len(items)) > need {
+			items = items[:need]
+			if lastEvaluatedKey, err = startKeyOf(items[need-1], lastKey, keys); err != nil {
+				return nil, nil, err
+			}
+			break
+		}
+		if len(lastEvaluatedKey) == 0 || (limit > 0 && int64(len(items)) >= need) {
+			break
+		}
+		query.ExclusiveStartKey = lastEvaluatedKey
+	}
+	if int64(len(items)) < offset {
+		offset = int64(len(items))
+	}
+	if len(lastEvaluatedKey) == 0 {
+		lastEvaluatedKey = nil
+	}
+	return items[offset:], lastEvaluatedKey, nil
+}
+
+// startKeyOf returns the start key after the item, with the attributes of the LastEvaluatedKey, or the key attributes if it is empty.
+func startKeyOf(item map[string]*dynamodb.AttributeValue, lastEvaluatedKey map[string]*dynamodb.AttributeValue, keys func() ([]string, error)) (map[string]*dynamodb.AttributeValue, error) {
+	var names []string
+	for name := range lastEvaluatedKey {
+		names = append(names, name)
+	}
+	if len(names) == 0 && keys != nil {
+		var err error
+		if names, err = keys(); err != nil {
+			return nil, err
+		}
+	}
+	if len(names) == 0 {
+		return nil, ErrUnknownStartKey
+	}
+	key := make(map[string]*dynamodb.AttributeValue)
+	for _, name := range names {
+		if v, ok := item[name]; ok {
+			key[name] = v
+		}
+	}
+	return key, nil
+}
+
+// EncodeStartKey encodes the start key of the next page as a page token.
+func EncodeStartKey(key map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func DecodeStartKey(token string) (map[string]*dynamodb.AttributeValue, error) {
+	if len(token) == 0 {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var key map[string]*dynamodb.AttributeValue
+	err = json.Unmarshal(data, &key)
+	return key, err
+}
+
+// GetKeyNames returns the key attributes of the table, followed by those of the index if it is not empty.
+func GetKeyNames(ctx context.Context, db *dynamodb.DynamoDB, tableName string, indexName string) ([]string, error) {
+	output, err := db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return nil, err
+	}
+	return keyNamesOf(output.Table, indexName), nil
+}
+
+func keyNamesOf(table *dynamodb.TableDescription, indexName string) []string {
+	keys := getKeyNames(table.KeySchema)
+	if len(indexName) == 0 {
+		return keys
+	}
+	for _, index := range GetSecondaryIndexes(table) {
+		if index.IndexName != indexName {
+			continue
+		}
+		for _, key := range index.Keys {
+			if !CheckKeys(key, keys) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,215 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// readEven reads the items 1 to 10 with a filter on the even ids, by pages of 4 items as DynamoDB reads pages of 1 MB before the filter.
+func readEven(reads *int) func(dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	return func(query dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+		*reads++
+		start := 1
+		if query.ExclusiveStartKey != nil {
+			id, _ := strconv.Atoi(aws.StringValue(query.ExclusiveStartKey["id"].N))
+			start = id + 1
+		}
+		var items []map[string]*dynamodb.AttributeValue
+		var lastKey map[string]*dynamodb.AttributeValue
+		for id := start; id <= 10; id++ {
+			item := map[string]*dynamodb.AttributeValue{"id": {N: aws.String(strconv.Itoa(id))}, "name": {S: aws.String("item " + strconv.Itoa(id))}}
+			if id%2 == 0 {
+				items = append(items, item)
+			}
+			if id-start+1 >= 4 {
+				if id < 10 {
+					lastKey = map[string]*dynamodb.AttributeValue{"id": item["id"]}
+				}
+				break
+			}
+		}
+		return items, lastKey, nil
+	}
+}
+
+func ids(items []map[string]*dynamodb.AttributeValue) []string {
+	var s []string
+	for _, item := range items {
+		s = append(s, aws.StringValue(item["id"].N))
+	}
+	return s
+}
+
+func TestReadPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		startKey string
+		offset   int64
+		limit    int64
+		keys     []string
+		items    []string
+		next     string
+		reads    int
+	}{
+		{"first page", "", 0, 2, nil, []string{"2", "4"}, "4", 1},
+		{"cut after the last returned item", "", 0, 3, nil, []string{"2", "4", "6"}, "6", 2},
+		{"offset", "", 2, 2, nil, []string{"6", "8"}, "8", 2},
+		{"resume after the start key", "4", 0, 2, nil, []string{"6", "8"}, "8", 1},
+		{"cut on the last page", "6", 0, 1, []string{"id"}, []string{"8"}, "8", 1},
+		{"last page", "6", 0, 3, nil, []string{"8", "10"}, "", 1},
+		{"no limit", "", 0, 0, nil, []string{"2", "4", "6", "8", "10"}, "", 3},
+		{"offset after the end", "", 8, 2, nil, nil, "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := dynamodb.QueryInput{TableName: aws.String("items")}
+			if len(tt.startKey) > 0 {
+				query.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{"id": {N: aws.String(tt.startKey)}}
+			}
+			reads := 0
+			items, lastKey, err := readPage(query, tt.offset, tt.limit, readEven(&reads), func() ([]string, error) {
+				return tt.keys, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(items); !reflect.DeepEqual(got, tt.items) {
+				t.Errorf("items = %v, want %v", got, tt.items)
+			}
+			next := ""
+			if lastKey != nil {
+				if len(lastKey) != 1 {
+					t.Errorf("next page starts after %v, want the key attributes only", lastKey)
+				}
+				next = aws.StringValue(lastKey["id"].N)
+			}
+			if next != tt.next {
+				t.Errorf("next page starts after %q, want %q", next, tt.next)
+			}
+			if reads != tt.reads {
+				t.Errorf("%d reads, want %d", reads, tt.reads)
+			}
+		})
+	}
+}
+
+func TestReadPageUnknownKeys(t *testing.T) {
+	query := dynamodb.QueryInput{TableName: aws.String("items"), ExclusiveStartKey: map[string]*dynamodb.AttributeValue{"id": {N: aws.String("6")}}}
+	reads := 0
+	if _, _, err := readPage(query, 0, 1, readEven(&reads), nil); err != ErrUnknownStartKey {
+		t.Errorf("err = %v, want %v", err, ErrUnknownStartKey)
+	}
+}
+
+func TestReadPageKeysOfIndex(t *testing.T) {
+	item := func(id string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}, "status": {S: aws.String("paid")}, "amount": {N: aws.String("10")}}
+	}
+	db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		switch r.Operation {
+		case "Query":
+			return &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{item("o1"), item("o2"), item("o3")}}
+		case "DescribeTable":
+			return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+				KeySchema: []*dynamodb.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeHash)}},
+				GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{{
+					IndexName: aws.String("byStatus"),
+					KeySchema: []*dynamodb.KeySchemaElement{{AttributeName: aws.String("status"), KeyType: aws.String(dynamodb.KeyTypeHash)}},
+				}},
+			}}
+		}
+		return nil
+	})
+	query := dynamodb.QueryInput{TableName: aws.String("orders"), IndexName: aws.String("byStatus"), KeyConditionExpression: aws.String("#0 = :0")}
+	items, lastKey, err := ReadPage(context.Background(), db, query, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("o2")}, "status": {S: aws.String("paid")}}
+	if len(items) != 2 || !reflect.DeepEqual(lastKey, want) {
+		t.Errorf("ReadPage = %d items, %v, want the key of the table and of the index of o2", len(items), lastKey)
+	}
+	var input dynamodb.QueryInput
+	fake.Requests("Query")[0].Decode(t, &input)
+	if input.Limit != nil {
+		t.Errorf("limit = %d, want full pages", aws.Int64Value(input.Limit))
+	}
+}
+
+func TestStartKey(t *testing.T) {
+	key := map[string]*dynamodb.AttributeValue{
+		"id":        {S: aws.String("o-1")},
+		"createdAt": {N: aws.String("1700000000")},
+	}
+	token, err := EncodeStartKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeStartKey(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, key) {
+		t.Errorf("DecodeStartKey(EncodeStartKey(%v)) = %v", key, decoded)
+	}
+	if token, _ = EncodeStartKey(nil); token != "" {
+		t.Errorf("EncodeStartKey(nil) = %q, want empty", token)
+	}
+	if decoded, _ = DecodeStartKey(""); decoded != nil {
+		t.Errorf("DecodeStartKey(\"\") = %v, want nil", decoded)
+	}
+	if _, err = DecodeStartKey("not a token"); err == nil {
+		t.Error("DecodeStartKey accepts an invalid token")
+	}
+}
+
+func TestSearchExcludesBoundaries(t *testing.T) {
+	item := func(id string, createdAt string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}, "createdAt": {S: aws.String(createdAt)}}
+	}
+	// The key condition is createdAt BETWEEN 2026-01-01 AND 2026-02-01, the end of the range is exclusive.
+	items := []map[string]*dynamodb.AttributeValue{
+		item("o1", "2026-01-01T00:00:00Z"),
+		item("o2", "2026-01-15T00:00:00Z"),
+		item("o3", "2026-02-01T00:00:00Z"),
+	}
+	db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		return &dynamodb.QueryOutput{Items: items, Count: aws.Int64(int64(len(items)))}
+	})
+	query := dynamodb.QueryInput{
+		TableName:                 aws.String("orders"),
+		KeyConditionExpression:    aws.String("#0 = :0 AND #1 BETWEEN :1 AND :2"),
+		ExpressionAttributeNames:  map[string]*string{"#0": aws.String("customerId"), "#1": aws.String("createdAt")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":0": {S: aws.String("c1")}, ":1": {S: aws.String("2026-01-01T00:00:00Z")}, ":2": {S: aws.String("2026-02-01T00:00:00Z")}},
+	}
+	b := NewQuerySearchBuilder(db, reflect.TypeOf(struct {
+		Id        string `dynamodbav:"id"`
+		CreatedAt string `dynamodbav:"createdAt"`
+	}{}), func(m interface{}) (dynamodb.QueryInput, error) {
+		return query, nil
+	})
+	b.BuildBoundaries = func(m interface{}) ([]Boundary, error) {
+		return []Boundary{{Name: "createdAt", Value: &dynamodb.AttributeValue{S: aws.String("2026-02-01T00:00:00Z")}}}, nil
+	}
+	b.Count = CountExact
+	var results []struct {
+		Id string `dynamodbav:"id"`
+	}
+	total, _, err := b.Search(context.Background(), nil, &results, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(results) != 2 || results[1].Id != "o2" {
+		t.Errorf("Search = %d, %v, want the items before the end of the range", total, results)
+	}
+	requests := fake.Requests("Query")
+	var count dynamodb.QueryInput
+	requests[len(requests)-1].Decode(t, &count)
+	if aws.StringValue(count.Select) != dynamodb.SelectSpecificAttributes || aws.StringValue(count.ExpressionAttributeNames[aws.StringValue(count.ProjectionExpression)]) != "createdAt" {
+		t.Errorf("count = %v, want the boundary attribute read instead of a COUNT", count)
+	}
+}
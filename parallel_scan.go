@@ -0,0 +1,157 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ParallelScanner scans a table with one worker per segment and decodes the items into the model type.
+type ParallelScanner struct {
+	Database  *dynamodb.DynamoDB
+	ModelType reflect.Type
+	Segments  int
+	// ReadCapacity is the maximum read capacity units consumed per second by all workers, 0 is unlimited.
+	ReadCapacity float64
+	Map          func(ctx context.Context, model interface{}) (interface{}, error)
+}
+
+func NewParallelScanner(db *dynamodb.DynamoDB, modelType reflect.Type, segments int, options ...func(context.Context, interface{}) (interface{}, error)) *ParallelScanner {
+	var mp func(context.Context, interface{}) (interface{}, error)
+	if len(options) > 0 && options[0] != nil {
+		mp = options[0]
+	}
+	return &ParallelScanner{Database: db, ModelType: modelType, Segments: segments, Map: mp}
+}
+
+// Scan calls handle with a pointer to each decoded model. handle is called concurrently by the workers, and a worker does not read its next page before handle returns.
+// The first error cancels the other workers and is returned.
+func (s *ParallelScanner) Scan(ctx context.Context, query dynamodb.ScanInput, handle func(ctx context.Context, model interface{}) error) error {
+	segments := s.Segments
+	if segments < 1 {
+		segments = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	limiter := newCapacityLimiter(s.ReadCapacity)
+	if limiter != nil {
+		query.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	}
+	var wg sync.WaitGroup
+	var once sync.Once
+	var err error
+	for i := 0; i < segments; i++ {
+		segment := query
+		if segments > 1 {
+			segment.Segment = aws.Int64(int64(i))
+			segment.TotalSegments = aws.Int64(int64(segments))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if er1 := s.scanSegment(ctx, segment, limiter, handle); er1 != nil {
+				once.Do(func() {
+					err = er1
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return err
+}
+
+func (s *ParallelScanner) scanSegment(ctx context.Context, query dynamodb.ScanInput, limiter *capacityLimiter, handle func(ctx context.Context, model interface{}) error) error {
+	for {
+		output, err := s.Database.ScanWithContext(ctx, &query)
+		if err != nil {
+			return err
+		}
+		for _, item := range output.Items {
+			model := reflect.New(s.ModelType).Interface()
+			if err = dynamodbattribute.UnmarshalMap(item, model); err != nil {
+				return err
+			}
+			if s.Map != nil {
+				if _, err = s.Map(ctx, model); err != nil {
+					return err
+				}
+			}
+			if err = handle(ctx, model); err != nil {
+				return err
+			}
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		if limiter != nil && output.ConsumedCapacity != nil {
+			if err = limiter.wait(ctx, aws.Float64Value(output.ConsumedCapacity.CapacityUnits)); err != nil {
+				return err
+			}
+		}
+		query.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// ScanToChannel sends a pointer to each decoded model to the channel, and closes it at the end.
+// A full channel blocks the workers, so the reader controls the pace of the scan.
+func (s *ParallelScanner) ScanToChannel(ctx context.Context, query dynamodb.ScanInput, ch chan<- interface{}) error {
+	defer close(ch)
+	return s.Scan(ctx, query, func(ctx context.Context, model interface{}) error {
+		select {
+		case ch <- model:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// ScanAll returns a pointer to a slice of all models, in no particular order.
+func (s *ParallelScanner) ScanAll(ctx context.Context, query dynamodb.ScanInput) (interface{}, error) {
+	results := reflect.New(reflect.SliceOf(s.ModelType))
+	var mu sync.Mutex
+	err := s.Scan(ctx, query, func(ctx context.Context, model interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		results.Elem().Set(reflect.Append(results.Elem(), reflect.ValueOf(model).Elem()))
+		return nil
+	})
+	return results.Interface(), err
+}
+
+// capacityLimiter delays the workers so that the consumed capacity stays under rate units per second.
+type capacityLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	start    time.Time
+	consumed float64
+}
+
+func newCapacityLimiter(rate float64) *capacityLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &capacityLimiter{rate: rate, start: time.Now()}
+}
+
+func (l *capacityLimiter) wait(ctx context.Context, units float64) error {
+	l.mu.Lock()
+	l.consumed += units
+	ready := l.start.Add(time.Duration(l.consumed / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	delay := time.Until(ready)
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,20 +2,50 @@ package dynamodb
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"strconv"
 	"time"
 )
 
+var (
+	ErrPasscodeNotFound = errors.New("passcode not found")
+	ErrPasscodeExpired  = errors.New("passcode expired")
+	ErrPasscodeWrong    = errors.New("wrong passcode")
+	ErrPasscodeLocked   = errors.New("passcode locked")
+	ErrPasscodeSecret   = errors.New("passcode secret is required")
+	ErrPasscodeHashed   = errors.New("passcode is stored as a hash and cannot be loaded, use Verify")
+)
+
+// PasscodeRepository stores one time codes: the code is saved as an HMAC of the secret, salted with the id, and the expiry is a TTL attribute.
 type PasscodeRepository struct {
 	Database      *dynamodb.DynamoDB
 	tableName     string
 	idName        string
 	passcodeName  string
 	expiredAtName string
+	attemptsName  string
+	// MaxAttempts is the number of failed verifications which locks the passcode.
+	MaxAttempts int
+	// Secret is the key of the HMAC of the passcode, without it a 6 digit code could be found from its hash.
+	Secret []byte
+	Now    func() time.Time
 }
 
+// NewPasscodeRepository creates the repository without secret, Save and Verify fail with ErrPasscodeSecret until Secret is set.
 func NewPasscodeRepository(db *dynamodb.DynamoDB, tableName string, options ...string) *PasscodeRepository {
-	var keyName, passcodeName, expiredAtName string
+	return NewPasscodeRepositoryWithSecret(db, tableName, nil, options...)
+}
+
+// NewPasscodeRepositoryWithSecret creates the repository with the secret of the passcode hashes.
+func NewPasscodeRepositoryWithSecret(db *dynamodb.DynamoDB, tableName string, secret []byte, options ...string) *PasscodeRepository {
+	var keyName, passcodeName, expiredAtName, attemptsName string
 	if len(options) >= 1 && len(options[0]) > 0 {
 		expiredAtName = options[0]
 	} else {
@@ -31,23 +61,126 @@ func NewPasscodeRepository(db *dynamodb.DynamoDB, tableName string, options ...s
 	} else {
 		passcodeName = "passcode"
 	}
-	return &PasscodeRepository{db, tableName, keyName, passcodeName, expiredAtName}
+	if len(options) >= 4 && len(options[3]) > 0 {
+		attemptsName = options[3]
+	} else {
+		attemptsName = "attempts"
+	}
+	return &PasscodeRepository{Database: db, tableName: tableName, idName: keyName, passcodeName: passcodeName, expiredAtName: expiredAtName, attemptsName: attemptsName, MaxAttempts: 5, Secret: secret}
+}
+
+func (p *PasscodeRepository) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+// Hash returns the stored form of the passcode of the id.
+func (p *PasscodeRepository) Hash(id string, passcode string) string {
+	h := hmac.New(sha256.New, p.Secret)
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(passcode))
+	return hex.EncodeToString(h.Sum(nil))
 }
 
+// Save replaces the passcode of the id and resets its failed attempts.
+// The hash is stored in a string set, so that Verify can consume it in the same update which counts the attempt.
 func (p *PasscodeRepository) Save(ctx context.Context, id string, passcode string, expiredAt time.Time) (int64, error) {
+	if len(p.Secret) == 0 {
+		return 0, ErrPasscodeSecret
+	}
 	pass := make(map[string]interface{})
 	pass[p.idName] = id
-	pass[p.passcodeName] = passcode
-	pass[p.expiredAtName] = expiredAt
-	return UpsertOne(ctx, p.Database, p.tableName, []string{p.idName}, pass)
+	pass[p.expiredAtName] = TTL(expiredAt)
+	pass[p.attemptsName] = 0
+	item, err := dynamodbattribute.MarshalMap(pass)
+	if err != nil {
+		return 0, err
+	}
+	item[p.passcodeName] = &dynamodb.AttributeValue{SS: []*string{aws.String(p.Hash(id, passcode))}}
+	return putItem(ctx, p.Database, p.tableName, item, nil, conditionFailed, WriteOptions{})
 }
 
+// Load returns the expiry of the passcode.
+//
+// Deprecated: the passcode is stored as a hash, so Load returns ErrPasscodeHashed instead of the passcode; use Verify.
 func (p *PasscodeRepository) Load(ctx context.Context, id string) (string, time.Time, error) {
-	ok, data, err := FindOneAndReturnMapData(ctx, p.Database, p.tableName, []string{p.idName}, id)
-	if err != nil || !ok {
+	item, err := GetItem(ctx, p.Database, p.tableName, []string{p.idName}, id)
+	if err != nil || len(item) == 0 {
 		return "", time.Now(), err
 	}
-	return data[p.passcodeName].(string), data[p.passcodeName].(time.Time), nil
+	var expiredAt TTL
+	if v, ok := item[p.expiredAtName]; ok {
+		if err = expiredAt.UnmarshalDynamoDBAttributeValue(v); err != nil {
+			return "", time.Now(), err
+		}
+	}
+	return "", expiredAt.Time(), ErrPasscodeHashed
+}
+
+// Verify consumes the passcode if it matches, is not expired and not locked, and counts the attempt, in one conditional update.
+// It returns ErrPasscodeNotFound, ErrPasscodeExpired, ErrPasscodeWrong or ErrPasscodeLocked.
+func (p *PasscodeRepository) Verify(ctx context.Context, id string, passcode string) error {
+	if len(p.Secret) == 0 {
+		return ErrPasscodeSecret
+	}
+	keyMap, err := buildKeyMap([]string{p.idName}, id)
+	if err != nil {
+		return err
+	}
+	hash := p.Hash(id, passcode)
+	now := p.now()
+	update, condition := p.verifyExpression(hash, now)
+	var old map[string]interface{}
+	options := WriteOptions{ReturnValuesOnConditionCheckFailure: true, ReturnValues: dynamodb.ReturnValueUpdatedOld, Result: &old}
+	_, err = updateItem(ctx, p.Database, p.tableName, keyMap, update, &condition, conditionFailed, options)
+	if err != nil {
+		if e, ok := err.(*ConditionalCheckFailedError); ok {
+			if er2 := p.checkState(e.Item, now); er2 != nil {
+				return er2
+			}
+			return ErrPasscodeNotFound
+		}
+		return err
+	}
+	if hashes, ok := old[p.passcodeName].([]string); ok {
+		for _, h := range hashes {
+			if h == hash {
+				return nil
+			}
+		}
+	}
+	return ErrPasscodeWrong
+}
+
+// verifyExpression adds one attempt and deletes the hash from the passcode set, which consumes a matching passcode and leaves a wrong one.
+// The condition is that the passcode is not consumed, expired or locked.
+func (p *PasscodeRepository) verifyExpression(hash string, now time.Time) (expression.UpdateBuilder, expression.ConditionBuilder) {
+	attempts := expression.Name(p.attemptsName)
+	code := expression.Name(p.passcodeName)
+	update := expression.Add(attempts, expression.Value(1)).
+		Delete(code, expression.Value(&dynamodb.AttributeValue{SS: []*string{aws.String(hash)}}))
+	condition := expression.AttributeExists(code).
+		And(expression.Name(p.expiredAtName).GreaterThan(expression.Value(now.Unix()))).
+		And(expression.AttributeNotExists(attempts).Or(attempts.LessThan(expression.Value(p.MaxAttempts))))
+	return update, condition
+}
+
+func (p *PasscodeRepository) checkState(item map[string]*dynamodb.AttributeValue, now time.Time) error {
+	if _, ok := item[p.passcodeName]; !ok {
+		return ErrPasscodeNotFound
+	}
+	if v, ok := item[p.expiredAtName]; !ok || v.N == nil || IsExpired(item, p.expiredAtName, now) {
+		return ErrPasscodeExpired
+	}
+	if v, ok := item[p.attemptsName]; ok && v.N != nil {
+		if attempts, err := strconv.Atoi(aws.StringValue(v.N)); err == nil && attempts >= p.MaxAttempts {
+			return ErrPasscodeLocked
+		}
+	}
+	return nil
 }
 
 func (p *PasscodeRepository) Delete(ctx context.Context, id string) (int64, error) {
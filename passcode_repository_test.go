@@ -0,0 +1,101 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"testing"
+	"time"
+)
+
+func TestPasscodeHash(t *testing.T) {
+	p := NewPasscodeRepositoryWithSecret(nil, "passcodes", []byte("secret"))
+	other := NewPasscodeRepositoryWithSecret(nil, "passcodes", []byte("other"))
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"same code", p.Hash("u1", "123456"), p.Hash("u1", "123456"), true},
+		{"other code", p.Hash("u1", "123456"), p.Hash("u1", "123457"), false},
+		{"other id", p.Hash("u1", "123456"), p.Hash("u2", "123456"), false},
+		{"no ambiguity between id and code", p.Hash("u1", "23456"), p.Hash("u12", "3456"), false},
+		{"other secret", p.Hash("u1", "123456"), other.Hash("u1", "123456"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.equal {
+				t.Errorf("hashes %s and %s, want equal %v", tt.a, tt.b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestPasscodeWithoutSecret(t *testing.T) {
+	p := NewPasscodeRepository(nil, "passcodes")
+	if _, err := p.Save(context.Background(), "u1", "123456", time.Now()); err != ErrPasscodeSecret {
+		t.Errorf("Save error = %v, want %v", err, ErrPasscodeSecret)
+	}
+	if err := p.Verify(context.Background(), "u1", "123456"); err != ErrPasscodeSecret {
+		t.Errorf("Verify error = %v, want %v", err, ErrPasscodeSecret)
+	}
+}
+
+func TestPasscodeVerifyExpression(t *testing.T) {
+	p := NewPasscodeRepositoryWithSecret(nil, "passcodes", []byte("secret"))
+	now := time.Unix(1700000000, 0)
+	update, condition := p.verifyExpression("h", now)
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]string{}
+	for k, v := range expr.Names() {
+		names[aws.StringValue(v)] = k
+	}
+	values := expr.Values()
+	got := aws.StringValue(expr.Update())
+	want := "ADD " + names["attempts"] + " :2\nDELETE " + names["passcode"] + " :3\n"
+	if got != want {
+		t.Errorf("update = %q, want %q", got, want)
+	}
+	if v := values[":3"]; v == nil || len(v.SS) != 1 || aws.StringValue(v.SS[0]) != "h" {
+		t.Errorf("deleted hash = %v, want the set of h", v)
+	}
+	got = aws.StringValue(expr.Condition())
+	want = "((attribute_exists (" + names["passcode"] + ")) AND (" + names["expiredAt"] + " > :0)) AND ((attribute_not_exists (" + names["attempts"] + ")) OR (" + names["attempts"] + " < :1))"
+	if got != want {
+		t.Errorf("condition = %q, want %q", got, want)
+	}
+	if aws.StringValue(values[":0"].N) != "1700000000" || aws.StringValue(values[":1"].N) != "5" {
+		t.Errorf("condition values = %v, %v", values[":0"], values[":1"])
+	}
+}
+
+func TestPasscodeCheckState(t *testing.T) {
+	p := NewPasscodeRepositoryWithSecret(nil, "passcodes", []byte("secret"))
+	now := time.Unix(1700000000, 0)
+	code := &dynamodb.AttributeValue{SS: []*string{aws.String("h")}}
+	future := &dynamodb.AttributeValue{N: aws.String("1700000060")}
+	past := &dynamodb.AttributeValue{N: aws.String("1699999940")}
+	tests := []struct {
+		name string
+		item map[string]*dynamodb.AttributeValue
+		want error
+	}{
+		{"missing", nil, ErrPasscodeNotFound},
+		{"consumed", map[string]*dynamodb.AttributeValue{"id": {S: aws.String("u1")}, "expiredAt": future, "attempts": {N: aws.String("1")}}, ErrPasscodeNotFound},
+		{"expired", map[string]*dynamodb.AttributeValue{"passcode": code, "expiredAt": past, "attempts": {N: aws.String("0")}}, ErrPasscodeExpired},
+		{"expired and locked", map[string]*dynamodb.AttributeValue{"passcode": code, "expiredAt": past, "attempts": {N: aws.String("5")}}, ErrPasscodeExpired},
+		{"locked", map[string]*dynamodb.AttributeValue{"passcode": code, "expiredAt": future, "attempts": {N: aws.String("5")}}, ErrPasscodeLocked},
+		{"valid", map[string]*dynamodb.AttributeValue{"passcode": code, "expiredAt": future, "attempts": {N: aws.String("4")}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.checkState(tt.item, now); err != tt.want {
+				t.Errorf("checkState = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -10,43 +10,94 @@ import (
 	"strings"
 )
 
+// BuildSearchResult decodes the page at pageIndex into results and returns the number of items of the page.
+// The pages are filled with limit matching items, all items are returned if limit <= 0.
 func BuildSearchResult(ctx context.Context, db *dynamodb.DynamoDB, results interface{}, query dynamodb.ScanInput, limit int64, pageIndex int64, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error) {
-	var databaseQuery *dynamodb.ScanOutput
-	var mp func(context.Context, interface{}) (interface{}, error)
-	if len(options) > 0 && options[0] != nil {
-		mp = options[0]
+	return BuildQueryResult(ctx, db, results, ToQueryInput(&query), limit, pageIndex, options...)
+}
+
+// AddFilter ANDs the filter with the filter expression of the query, the placeholders of the filter must not be used by the query.
+func AddFilter(query *dynamodb.ScanInput, filter string, names map[string]*string, values map[string]*dynamodb.AttributeValue) {
+	query.FilterExpression, query.ExpressionAttributeNames, query.ExpressionAttributeValues = addFilter(query.FilterExpression, query.ExpressionAttributeNames, query.ExpressionAttributeValues, filter, names, values)
+}
+
+// AddQueryFilter is AddFilter for a QueryInput.
+func AddQueryFilter(query *dynamodb.QueryInput, filter string, names map[string]*string, values map[string]*dynamodb.AttributeValue) {
+	query.FilterExpression, query.ExpressionAttributeNames, query.ExpressionAttributeValues = addFilter(query.FilterExpression, query.ExpressionAttributeNames, query.ExpressionAttributeValues, filter, names, values)
+}
+
+func addFilter(expr *string, exprNames map[string]*string, exprValues map[string]*dynamodb.AttributeValue, filter string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (*string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	if exprNames == nil {
+		exprNames = make(map[string]*string)
 	}
+	for k, v := range names {
+		exprNames[k] = v
+	}
+	if len(values) > 0 && exprValues == nil {
+		exprValues = make(map[string]*dynamodb.AttributeValue)
+	}
+	for k, v := range values {
+		exprValues[k] = v
+	}
+	if expr != nil && len(*expr) > 0 {
+		filter = fmt.Sprintf("(%s) AND %s", *expr, filter)
+	}
+	return &filter, exprNames, exprValues
+}
+
+// ToScanInput returns the Scan of a QueryInput without key condition.
+func ToScanInput(query *dynamodb.QueryInput) dynamodb.ScanInput {
+	return dynamodb.ScanInput{
+		TableName:                 query.TableName,
+		IndexName:                 query.IndexName,
+		Select:                    query.Select,
+		FilterExpression:          query.FilterExpression,
+		ProjectionExpression:      query.ProjectionExpression,
+		ExpressionAttributeNames:  query.ExpressionAttributeNames,
+		ExpressionAttributeValues: query.ExpressionAttributeValues,
+		ExclusiveStartKey:         query.ExclusiveStartKey,
+		ConsistentRead:            query.ConsistentRead,
+		Limit:                     query.Limit,
+	}
+}
+
+// ToQueryInput returns a QueryInput without key condition, which is run as the Scan.
+func ToQueryInput(query *dynamodb.ScanInput) dynamodb.QueryInput {
+	return dynamodb.QueryInput{
+		TableName:                 query.TableName,
+		IndexName:                 query.IndexName,
+		Select:                    query.Select,
+		FilterExpression:          query.FilterExpression,
+		ProjectionExpression:      query.ProjectionExpression,
+		ExpressionAttributeNames:  query.ExpressionAttributeNames,
+		ExpressionAttributeValues: query.ExpressionAttributeValues,
+		ExclusiveStartKey:         query.ExclusiveStartKey,
+		ConsistentRead:            query.ConsistentRead,
+		Limit:                     query.Limit,
+	}
+}
+
+// BuildQueryResult is BuildSearchResult for a Query, a QueryInput without key condition is run as a Scan.
+func BuildQueryResult(ctx context.Context, db *dynamodb.DynamoDB, results interface{}, query dynamodb.QueryInput, limit int64, pageIndex int64, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error) {
 	if pageIndex < 1 {
 		pageIndex = 1
 	}
-	if limit <= 0 {
-		_, er0 := FindAndDecode(ctx, db, &query, results)
-		return 0, er0
-	}
-	query.SetLimit(limit)
-	pageNum := 0
-	err := db.ScanPagesWithContext(ctx, &query,
-		func(page *dynamodb.ScanOutput, lastPage bool) bool {
-			pageNum++
-			if pageNum == int(pageIndex) {
-				databaseQuery = page
-			}
-			return pageNum <= int(pageIndex)
-		})
-	if err != nil {
-		return 0, err
+	var offset int64
+	if limit > 0 {
+		offset = (pageIndex - 1) * limit
 	}
-
-	err = dynamodbattribute.UnmarshalListOfMaps(databaseQuery.Items, &results)
+	items, _, err := ReadPage(ctx, db, query, offset, limit)
 	if err != nil {
 		return 0, err
 	}
-	count := *databaseQuery.Count
-	if mp == nil {
-		return count, nil
+	count := int64(len(items))
+	if err = dynamodbattribute.UnmarshalListOfMaps(items, results); err != nil {
+		return count, err
+	}
+	if len(options) > 0 && options[0] != nil {
+		_, err = MapModels(ctx, results, options[0])
 	}
-	_, er3 := MapModels(ctx, results, mp)
-	return count, er3
+	return count, err
 }
 
 func BuildKeyCondition(sm interface{}, index SecondaryIndex, keyword string) (expression.KeyConditionBuilder, error) {
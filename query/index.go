@@ -0,0 +1,136 @@
+package query
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	d "github.com/core-go/dynamodb"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type Explanation struct {
+	IndexName    string
+	KeyCondition string
+	Filter       string
+	Scan         bool
+	// Sort is the sort done client side.
+	Sort []d.Sort
+	// Boundaries are the exclusive bounds of the key condition, whose items are dropped client side.
+	Boundaries []d.Boundary
+}
+
+// Explain reports how the search model is queried: the selected index ("" for the table), the key condition and the filter with the attribute names, or if it falls back to a Scan.
+func (b *Builder) Explain(sm interface{}) (Explanation, error) {
+	query, index, sorts, boundaries, err := buildQueryInput(sm, b.ModelType, b.TableName, b.indexes())
+	if err != nil {
+		return Explanation{}, err
+	}
+	var args []string
+	for k, v := range query.ExpressionAttributeNames {
+		args = append(args, k, aws.StringValue(v))
+	}
+	replacer := strings.NewReplacer(sortByLength(args)...)
+	explanation := Explanation{
+		KeyCondition: replacer.Replace(aws.StringValue(query.KeyConditionExpression)),
+		Filter:       replacer.Replace(aws.StringValue(query.FilterExpression)),
+		Scan:         index == nil,
+		Sort:         sorts,
+		Boundaries:   boundaries,
+	}
+	if index != nil {
+		explanation.IndexName = index.IndexName
+	}
+	return explanation, nil
+}
+
+// sortByLength sorts the pairs of placeholder and name by the length of the placeholder, so that #10 is replaced before #1.
+func sortByLength(args []string) []string {
+	pairs := make([][2]string, 0, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, [2]string{args[i], args[i+1]})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return len(pairs[i][0]) > len(pairs[j][0])
+	})
+	sorted := make([]string, 0, len(args))
+	for _, pair := range pairs {
+		sorted = append(sorted, pair[0], pair[1])
+	}
+	return sorted
+}
+
+// selectIndex returns the index with the best key condition which projects the required attributes; among them, it prefers an index
+// which projects all attributes of the model, then the first one of the list. It returns nil if no index can be queried.
+func selectIndex(conditions []condition, required []string, all []string, indexes []d.SecondaryIndex) (*d.SecondaryIndex, *expression.KeyConditionBuilder, []condition) {
+	var selected *d.SecondaryIndex
+	var key *expression.KeyConditionBuilder
+	var filters []condition
+	score := 0
+	keys := tableKeys(indexes)
+	for i := range indexes {
+		k, f, keyScore, ok := splitKeyConditions(conditions, indexes[i].Keys)
+		if !ok || !projects(indexes[i], keys, required) {
+			continue
+		}
+		s := keyScore * 2
+		if projects(indexes[i], keys, all) {
+			s++
+		}
+		if s > score {
+			selected, key, filters, score = &indexes[i], k, f, s
+		}
+	}
+	return selected, key, filters
+}
+
+func tableKeys(indexes []d.SecondaryIndex) []string {
+	for _, index := range indexes {
+		if len(index.IndexName) == 0 {
+			return index.Keys
+		}
+	}
+	return nil
+}
+
+// projects is true if the index has all attributes; the table keys are projected to all indexes.
+func projects(index d.SecondaryIndex, tableKeys []string, attributes []string) bool {
+	if len(index.IndexName) == 0 || len(index.Projection) == 0 || index.Projection == dynamodb.ProjectionTypeAll {
+		return true
+	}
+	projected := make(map[string]bool)
+	for _, names := range [][]string{index.Keys, tableKeys, index.NonKeyAttributes} {
+		for _, name := range names {
+			projected[name] = true
+		}
+	}
+	for _, name := range attributes {
+		if !projected[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// attributes returns the attributes required by the search: the projected fields and the attributes of the conditions.
+func attributes(fields []string, conditions []condition) []string {
+	names := append([]string{}, fields...)
+	for _, c := range conditions {
+		if len(c.name) > 0 {
+			names = append(names, c.name)
+		}
+		names = append(names, c.names...)
+	}
+	return names
+}
+
+func modelAttributes(modelType reflect.Type) []string {
+	var names []string
+	for i := 0; i < modelType.NumField(); i++ {
+		if _, name, ok := d.GetFieldByIndex(modelType, i); ok && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
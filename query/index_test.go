@@ -0,0 +1,54 @@
+package query
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	d "github.com/core-go/dynamodb"
+	"github.com/core-go/search"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectIndex(t *testing.T) {
+	end := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	table := d.SecondaryIndex{Keys: []string{"customerId", "createdAt"}}
+	byStatus := d.SecondaryIndex{IndexName: "byStatus", Keys: []string{"customerId", "status"}}
+	statusKeys := d.SecondaryIndex{IndexName: "statusKeys", Keys: []string{"customerId", "status"}, Projection: dynamodb.ProjectionTypeKeysOnly}
+	statusAmount := d.SecondaryIndex{IndexName: "statusAmount", Keys: []string{"customerId", "status"}, Projection: dynamodb.ProjectionTypeInclude, NonKeyAttributes: []string{"amount"}}
+	tests := []struct {
+		name    string
+		indexes []d.SecondaryIndex
+		sm      *orderFilter
+		index   string
+		scan    bool
+	}{
+		{"sort key equality over range", []d.SecondaryIndex{table, byStatus}, &orderFilter{CustomerId: "c1", Status: "paid", CreatedAt: &search.TimeRange{EndTime: &end}}, "byStatus", false},
+		{"range over partition key only", []d.SecondaryIndex{byStatus, table}, &orderFilter{CustomerId: "c1", CreatedAt: &search.TimeRange{EndTime: &end}}, "", false},
+		{"keys only index without fields", []d.SecondaryIndex{statusKeys}, &orderFilter{CustomerId: "c1", Status: "paid"}, "", true},
+		{"keys only index with key fields", []d.SecondaryIndex{statusKeys}, &orderFilter{Filter: &search.Filter{Fields: []string{"customerId", "status"}}, CustomerId: "c1", Status: "paid"}, "statusKeys", false},
+		{"keys only index without a field", []d.SecondaryIndex{statusKeys}, &orderFilter{Filter: &search.Filter{Fields: []string{"amount"}}, CustomerId: "c1", Status: "paid"}, "", true},
+		{"included attribute", []d.SecondaryIndex{statusKeys, statusAmount}, &orderFilter{Filter: &search.Filter{Fields: []string{"amount"}}, CustomerId: "c1", Status: "paid"}, "statusAmount", false},
+		{"filter on a projected attribute", []d.SecondaryIndex{statusAmount}, &orderFilter{Filter: &search.Filter{Fields: []string{"amount"}}, CustomerId: "c1", Status: "paid", Amount: &search.NumberRange{Min: aws.Float64(10)}}, "statusAmount", false},
+		{"filter on an attribute which is not projected", []d.SecondaryIndex{statusKeys}, &orderFilter{CustomerId: "c1", Status: "paid", Amount: &search.NumberRange{Min: aws.Float64(10)}}, "", true},
+		{"all attributes preferred when as good", []d.SecondaryIndex{statusKeys, byStatus}, &orderFilter{CustomerId: "c1", Status: "paid"}, "byStatus", false},
+		{"all attributes required without fields", []d.SecondaryIndex{table, statusKeys}, &orderFilter{CustomerId: "c1", Status: "paid"}, "", false},
+		{"better keys preferred to all attributes with fields", []d.SecondaryIndex{table, statusKeys}, &orderFilter{Filter: &search.Filter{Fields: []string{"createdAt", "status"}}, CustomerId: "c1", Status: "paid"}, "statusKeys", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilderWithIndexes("orders", reflect.TypeOf(order{}), tt.indexes...)
+			query, err := b.BuildQueryInput(tt.sm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scan := query.KeyConditionExpression == nil
+			if scan != tt.scan || aws.StringValue(query.IndexName) != tt.index {
+				t.Errorf("index = %q, scan = %v, want %q, %v", aws.StringValue(query.IndexName), scan, tt.index, tt.scan)
+			}
+			if aws.StringValue(query.Select) == dynamodb.SelectAllProjectedAttributes {
+				t.Errorf("select = %s, want the attributes of the model or the fields", aws.StringValue(query.Select))
+			}
+		})
+	}
+}
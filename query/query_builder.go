@@ -1,12 +1,13 @@
 package query
 
 import (
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
 	d "github.com/core-go/dynamodb"
 	"github.com/core-go/search"
-	"log"
 	"reflect"
 	"strings"
 	"time"
@@ -16,26 +17,286 @@ type Builder struct {
 	TableName string
 	ModelType reflect.Type
 	Index     d.SecondaryIndex
+	// Indexes are the table and its indexes to select from, Index is used if it is empty.
+	Indexes []d.SecondaryIndex
 }
 
 func NewBuilder(tableName string, resultModelType reflect.Type, index d.SecondaryIndex) *Builder {
 	return &Builder{TableName: tableName, ModelType: resultModelType, Index: index}
 }
 
+func NewBuilderWithIndexes(tableName string, resultModelType reflect.Type, indexes ...d.SecondaryIndex) *Builder {
+	return &Builder{TableName: tableName, ModelType: resultModelType, Indexes: indexes}
+}
+
+// NewBuilderFromTable reads the key schema and the secondary indexes of the table with DescribeTable.
+func NewBuilderFromTable(db *dynamodb.DynamoDB, tableName string, resultModelType reflect.Type) (*Builder, error) {
+	table, err := d.FindTableDescription(db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	return NewBuilderWithIndexes(tableName, resultModelType, d.GetSecondaryIndexes(table)...), nil
+}
+
+// BuildQuery builds a Scan with all fields as filters.
+//
+// Deprecated: BuildQuery scans the whole table and ignores Index and Indexes; use BuildQueryInput, which queries by the keys of an index.
 func (b *Builder) BuildQuery(sm interface{}) (dynamodb.ScanInput, error) {
 	return Build(sm, b.ModelType, b.TableName)
 }
 
+func (b *Builder) BuildQueryInput(sm interface{}) (dynamodb.QueryInput, error) {
+	return BuildQueryInput(sm, b.ModelType, b.TableName, b.indexes()...)
+}
+
+// BuildBoundaries returns the exclusive bounds of the range on the sort key, whose items are read by the Query and must be dropped client side.
+func (b *Builder) BuildBoundaries(sm interface{}) ([]d.Boundary, error) {
+	_, _, _, boundaries, err := buildQueryInput(sm, b.ModelType, b.TableName, b.indexes())
+	return boundaries, err
+}
+
+func (b *Builder) indexes() []d.SecondaryIndex {
+	if len(b.Indexes) > 0 {
+		return b.Indexes
+	}
+	if len(b.Index.Keys) > 0 {
+		return []d.SecondaryIndex{b.Index}
+	}
+	return nil
+}
+
+// condition is the filter of a field of the search model; key is set when the filter can also be a key condition.
+// The condition of an OR group has no name, but the names of its fields.
+// The boundaries are the exclusive bounds which the key condition includes.
+type condition struct {
+	name       string
+	names      []string
+	group      string
+	filter     expression.ConditionBuilder
+	key        *expression.KeyConditionBuilder
+	equal      bool
+	boundaries []d.Boundary
+}
+
 func Build(sm interface{}, modelType reflect.Type, tableName string) (dynamodb.ScanInput, error) {
-	query := dynamodb.ScanInput{}
-	if _, ok := sm.(*search.Filter); ok {
+	conditions, fields, err := buildConditions(sm, modelType)
+	if err != nil {
+		return dynamodb.ScanInput{TableName: aws.String(tableName)}, err
+	}
+	query, err := buildInput(tableName, nil, nil, conditions, fields)
+	return d.ToScanInput(&query), err
+}
+
+// BuildQueryInput builds a Query on the best index whose partition key has an equal condition in the search model;
+// the condition on the sort key is a key condition too, the other fields are filters. Otherwise, it builds a Scan with a QueryInput without key condition.
+// An index must project the fields of the search model and the attributes of the filters; without fields, it must project all attributes
+// of the model, so a KEYS_ONLY or INCLUDE index is skipped for an index with all attributes, the table or a Scan.
+// An index without name is the table. A range on the sort key with both bounds and an exclusive bound is a BETWEEN key condition,
+// because the filter of a Query cannot use a key; the items on the exclusive bound, see BuildBoundaries, must be dropped client side.
+func BuildQueryInput(sm interface{}, modelType reflect.Type, tableName string, indexes ...d.SecondaryIndex) (dynamodb.QueryInput, error) {
+	query, _, _, _, err := buildQueryInput(sm, modelType, tableName, indexes)
+	return query, err
+}
+
+// buildQueryInput returns the query, the selected index, the sort which cannot be done by the query and the boundaries of its key condition.
+// The query is sorted by ScanIndexForward if the search model is sorted by the sort key of the index only.
+func buildQueryInput(sm interface{}, modelType reflect.Type, tableName string, indexes []d.SecondaryIndex) (dynamodb.QueryInput, *d.SecondaryIndex, []d.Sort, []d.Boundary, error) {
+	conditions, fields, err := buildConditions(sm, modelType)
+	if err != nil {
+		return dynamodb.QueryInput{TableName: aws.String(tableName)}, nil, nil, nil, err
+	}
+	sorts, err := buildSorts(sm, modelType)
+	if err != nil {
+		return dynamodb.QueryInput{TableName: aws.String(tableName)}, nil, nil, nil, err
+	}
+	all := modelAttributes(modelType)
+	required := fields
+	if len(required) == 0 {
+		required = all
+	}
+	index, keys, filters := selectIndex(conditions, attributes(required, conditions), all, indexes)
+	if index == nil {
+		query, err := buildInput(tableName, nil, nil, conditions, fields)
+		return query, nil, sorts, nil, err
+	}
+	var boundaries []d.Boundary
+	for _, c := range conditions {
+		if len(index.Keys) > 1 && c.name == index.Keys[1] {
+			boundaries = c.boundaries
+		}
+	}
+	var indexName *string
+	if len(index.IndexName) > 0 {
+		indexName = aws.String(index.IndexName)
+	}
+	query, err := buildInput(tableName, indexName, keys, filters, fields)
+	if len(sorts) == 1 && len(index.Keys) > 1 && sorts[0].Name == index.Keys[1] {
+		query.ScanIndexForward = aws.Bool(!sorts[0].Desc)
+		sorts = nil
+	}
+	return query, index, sorts, boundaries, err
+}
+
+// Scores of a key condition: on the partition key only, with a range on the sort key, or with an equal condition on the sort key.
+const (
+	partitionKeyScore = 1
+	sortKeyRangeScore = 2
+	sortKeyEqualScore = 3
+)
+
+// splitKeyConditions returns the key condition of the keys, the other conditions and the score of the key condition;
+// it fails if there is no equal condition on the partition key, or if a condition on a key cannot be a key condition,
+// because the filter of a Query cannot use the keys.
+func splitKeyConditions(conditions []condition, keys []string) (*expression.KeyConditionBuilder, []condition, int, bool) {
+	if len(keys) == 0 {
+		return nil, conditions, 0, false
+	}
+	var partition, sort []condition
+	var filters []condition
+	for _, c := range conditions {
+		if c.name == keys[0] {
+			partition = append(partition, c)
+		} else if len(keys) > 1 && c.name == keys[1] {
+			sort = append(sort, c)
+		} else if uses(c.names, keys) {
+			return nil, conditions, 0, false
+		} else {
+			filters = append(filters, c)
+		}
+	}
+	if len(partition) != 1 || !partition[0].equal || len(sort) > 1 || (len(sort) == 1 && sort[0].key == nil) {
+		return nil, conditions, 0, false
+	}
+	key := *partition[0].key
+	if len(sort) == 0 {
+		return &key, filters, partitionKeyScore, true
+	}
+	key = key.And(*sort[0].key)
+	if sort[0].equal {
+		return &key, filters, sortKeyEqualScore, true
+	}
+	return &key, filters, sortKeyRangeScore, true
+}
+
+func buildInput(tableName string, indexName *string, key *expression.KeyConditionBuilder, conditions []condition, fields []string) (dynamodb.QueryInput, error) {
+	query := dynamodb.QueryInput{TableName: aws.String(tableName), IndexName: indexName}
+	var projection *expression.ProjectionBuilder
+	for _, field := range fields {
+		proj := expression.NamesList(expression.Name(field))
+		if projection != nil {
+			proj = projection.AddNames(expression.Name(field))
+		}
+		projection = &proj
+	}
+	var filter *expression.ConditionBuilder
+	for i := range conditions {
+		if filter == nil {
+			filter = &conditions[i].filter
+		} else {
+			and := filter.And(conditions[i].filter)
+			filter = &and
+		}
+	}
+	if key == nil && filter == nil && projection == nil {
+		query.Select = aws.String(dynamodb.SelectAllAttributes)
 		return query, nil
 	}
-	var conditionBuilders []*expression.ConditionBuilder
-	var projectionBuilder *expression.ProjectionBuilder
+	builder := expression.NewBuilder()
+	if key != nil {
+		builder = builder.WithKeyCondition(*key)
+	}
+	if filter != nil {
+		builder = builder.WithFilter(*filter)
+	}
+	if projection != nil {
+		builder = builder.WithProjection(*projection)
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return query, err
+	}
+	query.KeyConditionExpression = expr.KeyCondition()
+	query.FilterExpression = expr.Filter()
+	query.ProjectionExpression = expr.Projection()
+	query.ExpressionAttributeNames = expr.Names()
+	query.ExpressionAttributeValues = expr.Values()
+	if projection != nil {
+		query.Select = aws.String(dynamodb.SelectSpecificAttributes)
+	} else {
+		query.Select = aws.String(dynamodb.SelectAllAttributes)
+	}
+	return query, nil
+}
+
+func equal(name string, v interface{}) condition {
+	key := expression.Key(name).Equal(expression.Value(v))
+	return condition{name: name, filter: expression.Name(name).Equal(expression.Value(v)), key: &key, equal: true}
+}
+
+// between builds the condition of a range, with nil min or max for a range with one bound.
+// The key condition of a range with both bounds is BETWEEN, the exclusive bounds are its boundaries.
+func between(name string, min interface{}, max interface{}, minExclusive bool, maxExclusive bool) *condition {
+	var arr []expression.ConditionBuilder
+	var key *expression.KeyConditionBuilder
+	if min != nil {
+		if minExclusive {
+			arr = append(arr, expression.Name(name).GreaterThan(expression.Value(min)))
+			k := expression.Key(name).GreaterThan(expression.Value(min))
+			key = &k
+		} else {
+			arr = append(arr, expression.Name(name).GreaterThanEqual(expression.Value(min)))
+			k := expression.Key(name).GreaterThanEqual(expression.Value(min))
+			key = &k
+		}
+	}
+	if max != nil {
+		if maxExclusive {
+			arr = append(arr, expression.Name(name).LessThan(expression.Value(max)))
+		} else {
+			arr = append(arr, expression.Name(name).LessThanEqual(expression.Value(max)))
+		}
+		if min != nil {
+			k := expression.Key(name).Between(expression.Value(min), expression.Value(max))
+			key = &k
+		} else if maxExclusive {
+			k := expression.Key(name).LessThan(expression.Value(max))
+			key = &k
+		} else {
+			k := expression.Key(name).LessThanEqual(expression.Value(max))
+			key = &k
+		}
+	}
+	if len(arr) == 0 {
+		return nil
+	}
+	c := condition{name: name, filter: arr[0], key: key}
+	if len(arr) > 1 {
+		c.filter = arr[0].And(arr[1])
+		if minExclusive {
+			c.boundaries = append(c.boundaries, boundary(name, min))
+		}
+		if maxExclusive {
+			c.boundaries = append(c.boundaries, boundary(name, max))
+		}
+	}
+	return &c
+}
+
+func boundary(name string, v interface{}) d.Boundary {
+	value, _ := dynamodbattribute.Marshal(v)
+	return d.Boundary{Name: name, Value: value}
+}
+
+func buildConditions(sm interface{}, modelType reflect.Type) ([]condition, []string, error) {
+	var conditions []condition
+	var fields []string
 	var keyword string
+	if _, ok := sm.(*search.Filter); ok {
+		return conditions, fields, nil
+	}
 	value := reflect.Indirect(reflect.ValueOf(sm))
 	for i := 0; i < value.NumField(); i++ {
+		n := len(conditions)
 		field := value.Field(i)
 		kind := field.Kind()
 		x := field.Interface()
@@ -64,213 +325,293 @@ func Build(sm interface{}, modelType reflect.Type, tableName string) (dynamodb.S
 			psv = s0
 		}
 		ks := kind.String()
-		if v, ok := x.(*search.Filter); ok {
+		tag := value.Type().Field(i).Tag
+		if operator, ok := tag.Lookup("operator"); ok {
+			if value.Field(i).Kind() != reflect.Ptr && field.IsZero() {
+				continue
+			}
+			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
+				c, err := operatorCondition(name, operator, field)
+				if err != nil {
+					return conditions, fields, err
+				}
+				conditions = append(conditions, *c)
+			}
+		} else if v, ok := x.(*search.Filter); ok {
 			if v.Excluding != nil && len(v.Excluding) > 0 {
 				if _, _, name, ok := getFieldByBson(modelType, "_id"); ok {
 					c := expression.Not(expression.Name(name).In(expression.Value(v.Excluding)))
-					conditionBuilders = append(conditionBuilders, &c)
-				}
-			}
-			if len(v.Fields) > 0 {
-				var proj expression.ProjectionBuilder
-				for idx := range v.Fields {
-					name := expression.Name(v.Fields[idx])
-					proj = proj.AddNames(name)
-					projectionBuilder = &proj
+					conditions = append(conditions, condition{name: name, filter: c})
 				}
 			}
+			fields = v.Fields
+			keyword = strings.TrimSpace(v.Q)
 			continue
 		} else if ps || ks == "string" {
-			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				var condition expression.ConditionBuilder
-				if !field.IsNil() {
-					if key, ok := value.Type().Field(i).Tag.Lookup("match"); ok {
-						if key == d.PREFIX {
-							condition = expression.Name(name).BeginsWith(psv)
-						} else if key == d.CONTAIN {
-							condition = expression.Name(name).Contains(psv)
-						} else if key == d.EQUAL {
-							condition = expression.Name(name).Equal(expression.Value(psv))
-						} else {
-							log.Panicf("match not support \"%v\" format\n", key)
-						}
-					}
-				} else if len(keyword) > 0 {
-					if key, ok := value.Type().Field(i).Tag.Lookup("keyword"); ok {
-						if key == d.PREFIX {
-							condition = expression.Name(name).BeginsWith(psv)
-						} else if key == d.CONTAIN {
-							condition = expression.Name(name).Contains(psv)
-						} else if key == d.EQUAL {
-							condition = expression.Name(name).Equal(expression.Value(psv))
-						} else {
-							log.Panicf("match not support \"%v\" format\n", key)
-						}
-					}
+			if j, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
+				match, _ := value.Type().Field(i).Tag.Lookup("match")
+				switch match {
+				case d.PREFIX:
+					name, psv := normalized(modelType.Field(j), name, psv)
+					key := expression.Key(name).BeginsWith(psv)
+					conditions = append(conditions, condition{name: name, filter: expression.Name(name).BeginsWith(psv), key: &key})
+				case d.CONTAIN:
+					name, psv := normalized(modelType.Field(j), name, psv)
+					conditions = append(conditions, condition{name: name, filter: expression.Name(name).Contains(psv)})
+				case d.EQUAL, "":
+					conditions = append(conditions, equal(name, psv))
+				default:
+					return conditions, fields, fmt.Errorf("match not support \"%v\" format", match)
 				}
-				conditionBuilders = append(conditionBuilders, &condition)
 			}
 		} else if rangeTime, ok := x.(*search.TimeRange); ok && rangeTime != nil {
 			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				gte := expression.Name(name).GreaterThanEqual(expression.Value(rangeTime.StartTime))
-				lt := expression.Name(name).LessThan(expression.Value(rangeTime.EndTime))
-				c := gte.And(lt)
-				conditionBuilders = append(conditionBuilders, &c)
+				if c := between(name, timeValue(rangeTime.StartTime), timeValue(rangeTime.EndTime), false, true); c != nil {
+					conditions = append(conditions, *c)
+				}
 			}
 		} else if rangeTime, ok := x.(search.TimeRange); ok {
 			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				gte := expression.Name(name).GreaterThanEqual(expression.Value(rangeTime.StartTime))
-				lt := expression.Name(name).LessThan(expression.Value(rangeTime.EndTime))
-				c := gte.And(lt)
-				conditionBuilders = append(conditionBuilders, &c)
+				if c := between(name, timeValue(rangeTime.StartTime), timeValue(rangeTime.EndTime), false, true); c != nil {
+					conditions = append(conditions, *c)
+				}
 			}
 		} else if rangeDate, ok := x.(*search.DateRange); ok && rangeDate != nil {
 			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				startDate := rangeDate.Min
-				endDate := rangeDate.Max.Add(time.Hour * 24)
-				gte := expression.Name(name).GreaterThanEqual(expression.Value(startDate))
-				lt := expression.Name(name).LessThan(expression.Value(endDate))
-				c := gte.And(lt)
-				conditionBuilders = append(conditionBuilders, &c)
+				if c := between(name, timeValue(rangeDate.Min), nextDay(rangeDate.Max), false, true); c != nil {
+					conditions = append(conditions, *c)
+				}
 			}
 		} else if rangeDate, ok := x.(search.DateRange); ok {
 			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				startDate := rangeDate.Min
-				endDate := rangeDate.Max.Add(time.Hour * 24)
-				gte := expression.Name(name).GreaterThanEqual(expression.Value(startDate))
-				lt := expression.Name(name).LessThan(expression.Value(endDate))
-				c := gte.And(lt)
-				conditionBuilders = append(conditionBuilders, &c)
+				if c := between(name, timeValue(rangeDate.Min), nextDay(rangeDate.Max), false, true); c != nil {
+					conditions = append(conditions, *c)
+				}
 			}
 		} else if numberRange, ok := x.(*search.NumberRange); ok && numberRange != nil {
 			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				var arr []*expression.ConditionBuilder
-				if numberRange.Min != nil {
-					gte := expression.Name(name).GreaterThanEqual(expression.Value(numberRange.Min))
-					arr = append(arr, &gte)
-				} else if numberRange.Lower != nil {
-					gt := expression.Name(name).GreaterThan(expression.Value(numberRange.Lower))
-					arr = append(arr, &gt)
-				}
-				if numberRange.Max != nil {
-					lte := expression.Name(name).LessThanEqual(expression.Value(numberRange.Max))
-					arr = append(arr, &lte)
-				} else if numberRange.Upper != nil {
-					lt := expression.Name(name).LessThan(expression.Value(numberRange.Upper))
-					arr = append(arr, &lt)
+				if c := numberCondition(name, *numberRange); c != nil {
+					conditions = append(conditions, *c)
 				}
-
-				var f *expression.ConditionBuilder
-				for idx := range arr {
-					if f == nil {
-						f = arr[idx]
-					} else {
-						f.And(*arr[idx])
-					}
-				}
-				conditionBuilders = append(conditionBuilders, f)
 			}
 		} else if numberRange, ok := x.(search.NumberRange); ok {
 			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				var arr []*expression.ConditionBuilder
-				if numberRange.Min != nil {
-					gte := expression.Name(name).GreaterThanEqual(expression.Value(numberRange.Min))
-					arr = append(arr, &gte)
-				} else if numberRange.Lower != nil {
-					gt := expression.Name(name).GreaterThan(expression.Value(numberRange.Lower))
-					arr = append(arr, &gt)
-				}
-				if numberRange.Max != nil {
-					lte := expression.Name(name).LessThanEqual(expression.Value(numberRange.Max))
-					arr = append(arr, &lte)
-				} else if numberRange.Upper != nil {
-					lt := expression.Name(name).LessThan(expression.Value(numberRange.Upper))
-					arr = append(arr, &lt)
-				}
-
-				var f *expression.ConditionBuilder
-				for idx := range arr {
-					if f == nil {
-						f = arr[idx]
-					} else {
-						f.And(*arr[idx])
-					}
+				if c := numberCondition(name, numberRange); c != nil {
+					conditions = append(conditions, *c)
 				}
-				conditionBuilders = append(conditionBuilders, f)
 			}
 		} else if kind == reflect.Slice {
-			if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-				condition := expression.Name(name).In(expression.Value(x))
-				conditionBuilders = append(conditionBuilders, &condition)
+			if j, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
+				if d.GetSetType(modelType.Field(j)) == d.StringSet {
+					var c *expression.ConditionBuilder
+					for k := 0; k < field.Len(); k++ {
+						contains := expression.Name(name).Contains(fmt.Sprint(field.Index(k).Interface()))
+						if c == nil {
+							c = &contains
+						} else {
+							or := c.Or(contains)
+							c = &or
+						}
+					}
+					if c != nil {
+						conditions = append(conditions, condition{name: name, filter: *c})
+					}
+				} else if field.Len() > 0 {
+					conditions = append(conditions, condition{name: name, filter: expression.Name(name).In(expression.Value(x))})
+				}
 			}
 		} else {
 			t := kind.String()
-			if _, ok := x.(*search.Filter); t == "bool" || (strings.Contains(t, "int") && x != 0) || (strings.Contains(t, "float") && x != 0) || (!ok && t == "string" && field.Len() > 0) || (!ok && t == "ptr" &&
-				field.Pointer() != 0) {
+			if t == "bool" || (strings.Contains(t, "int") && field.Interface() != 0) || (strings.Contains(t, "float") && field.Interface() != 0) {
 				if _, name, ok := d.GetFieldByName(modelType, value.Type().Field(i).Name); ok {
-					c := expression.Name(name).Equal(expression.Value(x))
-					conditionBuilders = append(conditionBuilders, &c)
+					if t == "bool" {
+						conditions = append(conditions, condition{name: name, filter: expression.Name(name).Equal(expression.Value(x))})
+					} else {
+						conditions = append(conditions, equal(name, field.Interface()))
+					}
 				}
 			}
 		}
+		if len(conditions) > n {
+			if not, _ := tag.Lookup("not"); not == "true" {
+				conditions[n].filter = expression.Not(conditions[n].filter)
+				conditions[n].key = nil
+				conditions[n].equal = false
+			}
+			conditions[n].group = tag.Get("group")
+		}
+	}
+	if len(keyword) > 0 {
+		c, err := keywordCondition(value.Type(), modelType, keyword)
+		if err != nil {
+			return conditions, fields, err
+		}
+		if c != nil {
+			conditions = append(conditions, *c)
+		}
 	}
+	return groupConditions(conditions), fields, nil
+}
 
-	//test := conditionBuilders[0]
-	var filter *expression.ConditionBuilder
+// normalized returns the shadow attribute and the normalized value if the field of the model is tagged normalize.
+func normalized(field reflect.StructField, name string, value string) (string, string) {
+	if shadow, ok := d.GetNormalizedName(field, name); ok {
+		return shadow, d.Normalize(value)
+	}
+	return name, value
+}
 
-	if conditionBuilders == nil && projectionBuilder == nil {
-		query = dynamodb.ScanInput{
-			TableName: aws.String(tableName),
-			Select:    aws.String(dynamodb.SelectAllAttributes),
+// keywordCondition ORs the keyword search of the fields tagged keyword:"prefix|contain".
+// A field normalized in the model searches the normalized keyword in its shadow attribute.
+// Otherwise, a field tagged lower:"attribute" searches the lowercase keyword in this attribute, a lowercase copy of the field.
+func keywordCondition(searchType reflect.Type, modelType reflect.Type, keyword string) (*condition, error) {
+	var c *condition
+	for i := 0; i < searchType.NumField(); i++ {
+		field := searchType.Field(i)
+		match, ok := field.Tag.Lookup("keyword")
+		if !ok {
+			continue
 		}
-		return query, nil
-	} else if conditionBuilders == nil && projectionBuilder != nil {
-		expr, _ := expression.NewBuilder().WithProjection(*projectionBuilder).Build()
-		query = dynamodb.ScanInput{
-			TableName:                aws.String(tableName),
-			ProjectionExpression:     expr.Projection(),
-			ExpressionAttributeNames: expr.Names(),
-			Select:                   aws.String(dynamodb.SelectSpecificAttributes),
+		j, name, ok := d.GetFieldByName(modelType, field.Name)
+		if !ok {
+			continue
 		}
-		return query, nil
-	} else if conditionBuilders != nil {
-		for i := range conditionBuilders {
-			if filter == nil {
-				filter = conditionBuilders[i]
-			} else {
-				filt := filter.And(*conditionBuilders[i])
-				filter = &filt
-			}
+		q := keyword
+		if shadow, ok := d.GetNormalizedName(modelType.Field(j), name); ok {
+			name = shadow
+			q = d.Normalize(keyword)
+		} else if lower, ok := field.Tag.Lookup("lower"); ok && len(lower) > 0 {
+			name = lower
+			q = strings.ToLower(keyword)
+		}
+		var filter expression.ConditionBuilder
+		switch match {
+		case d.PREFIX:
+			filter = expression.Name(name).BeginsWith(q)
+		case d.CONTAIN:
+			filter = expression.Name(name).Contains(q)
+		default:
+			return nil, fmt.Errorf("keyword not support \"%v\" format", match)
 		}
+		if c == nil {
+			c = &condition{names: []string{name}, filter: filter}
+		} else {
+			c.names = append(c.names, name)
+			c.filter = c.filter.Or(filter)
+		}
+	}
+	return c, nil
+}
 
-		if projectionBuilder == nil {
-			expr, _ := expression.NewBuilder().WithFilter(*filter).Build()
-			query = dynamodb.ScanInput{
-				TableName:                 aws.String(tableName),
-				FilterExpression:          expr.Filter(),
-				ExpressionAttributeNames:  expr.Names(),
-				ExpressionAttributeValues: expr.Values(),
-				Select:                    aws.String(dynamodb.SelectAllAttributes),
-			}
-			return query, nil
+// groupConditions ORs the conditions of the same group into one condition, at the position of the first condition of the group.
+func groupConditions(conditions []condition) []condition {
+	var grouped []condition
+	positions := make(map[string]int)
+	for _, c := range conditions {
+		if len(c.group) == 0 {
+			grouped = append(grouped, c)
+			continue
+		}
+		i, ok := positions[c.group]
+		if !ok {
+			positions[c.group] = len(grouped)
+			grouped = append(grouped, c)
+			continue
+		}
+		g := grouped[i]
+		if len(g.name) > 0 {
+			g.names = []string{g.name}
 		}
+		grouped[i] = condition{names: append(g.names, c.name), group: g.group, filter: g.filter.Or(c.filter)}
+	}
+	return grouped
+}
 
-		if projectionBuilder != nil {
-			expr, _ := expression.NewBuilder().WithFilter(*filter).WithProjection(*projectionBuilder).Build()
-			query = dynamodb.ScanInput{
-				TableName:                 aws.String(tableName),
-				ProjectionExpression:      expr.Projection(),
-				FilterExpression:          expr.Filter(),
-				ExpressionAttributeNames:  expr.Names(),
-				ExpressionAttributeValues: expr.Values(),
-				Select:                    aws.String(dynamodb.SelectSpecificAttributes),
+func uses(names []string, keys []string) bool {
+	for _, name := range names {
+		for _, key := range keys {
+			if name == key {
+				return true
 			}
-			return query, nil
 		}
+	}
+	return false
+}
 
+// operatorCondition builds the condition of the operator tag. The value of between is a slice of 2 items;
+// exists and not_exists apply to a bool, and a *bool false reverses them.
+func operatorCondition(name string, operator string, field reflect.Value) (*condition, error) {
+	v := field.Interface()
+	switch operator {
+	case "ne":
+		return &condition{name: name, filter: expression.Name(name).NotEqual(expression.Value(v))}, nil
+	case "lt":
+		key := expression.Key(name).LessThan(expression.Value(v))
+		return &condition{name: name, filter: expression.Name(name).LessThan(expression.Value(v)), key: &key}, nil
+	case "lte":
+		key := expression.Key(name).LessThanEqual(expression.Value(v))
+		return &condition{name: name, filter: expression.Name(name).LessThanEqual(expression.Value(v)), key: &key}, nil
+	case "gt":
+		key := expression.Key(name).GreaterThan(expression.Value(v))
+		return &condition{name: name, filter: expression.Name(name).GreaterThan(expression.Value(v)), key: &key}, nil
+	case "gte":
+		key := expression.Key(name).GreaterThanEqual(expression.Value(v))
+		return &condition{name: name, filter: expression.Name(name).GreaterThanEqual(expression.Value(v)), key: &key}, nil
+	case "between":
+		if (field.Kind() != reflect.Slice && field.Kind() != reflect.Array) || field.Len() != 2 {
+			return nil, fmt.Errorf("operator between of %s needs 2 values", name)
+		}
+		return between(name, field.Index(0).Interface(), field.Index(1).Interface(), false, false), nil
+	case "exists", "not_exists":
+		exists, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s of %s needs a bool", operator, name)
+		}
+		if exists == (operator == "exists") {
+			return &condition{name: name, filter: expression.AttributeExists(expression.Name(name))}, nil
+		}
+		return &condition{name: name, filter: expression.AttributeNotExists(expression.Name(name))}, nil
+	case "size_gt":
+		return &condition{name: name, filter: expression.Name(name).Size().GreaterThan(expression.Value(v))}, nil
+	case "type":
+		return &condition{name: name, filter: expression.Name(name).AttributeType(expression.DynamoDBAttributeType(fmt.Sprint(v)))}, nil
+	default:
+		return nil, fmt.Errorf("operator not support \"%v\" format", operator)
 	}
-	return query, nil
 }
+
+func numberCondition(name string, numberRange search.NumberRange) *condition {
+	var min, max interface{}
+	minExclusive, maxExclusive := false, false
+	if numberRange.Min != nil {
+		min = *numberRange.Min
+	} else if numberRange.Lower != nil {
+		min = *numberRange.Lower
+		minExclusive = true
+	}
+	if numberRange.Max != nil {
+		max = *numberRange.Max
+	} else if numberRange.Upper != nil {
+		max = *numberRange.Upper
+		maxExclusive = true
+	}
+	return between(name, min, max, minExclusive, maxExclusive)
+}
+
+func timeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+func nextDay(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Add(time.Hour * 24)
+}
+
 func getFieldByBson(modelType reflect.Type, bsonName string) (int, string, string, bool) {
 	numField := modelType.NumField()
 	for index := 0; index < numField; index++ {
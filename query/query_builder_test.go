@@ -0,0 +1,227 @@
+package query
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	d "github.com/core-go/dynamodb"
+	"github.com/core-go/search"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type order struct {
+	CustomerId string    `json:"customerId" dynamodbav:"customerId"`
+	CreatedAt  time.Time `json:"createdAt" dynamodbav:"createdAt"`
+	Amount     float64   `json:"amount" dynamodbav:"amount"`
+	Status     string    `json:"status" dynamodbav:"status"`
+}
+
+type orderFilter struct {
+	*search.Filter
+	CustomerId string
+	CreatedAt  *search.TimeRange
+	Amount     *search.NumberRange
+	Status     string
+}
+
+type orderDateFilter struct {
+	CustomerId string
+	CreatedAt  *search.DateRange
+}
+
+var orderIndexes = []d.SecondaryIndex{
+	{Keys: []string{"customerId", "createdAt"}},
+	{IndexName: "byCustomer", Keys: []string{"customerId"}},
+	{IndexName: "byAmount", Keys: []string{"customerId", "amount"}},
+}
+
+func TestBuildQueryInputRanges(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	low, high := 10.0, 20.0
+	tests := []struct {
+		name       string
+		sm         interface{}
+		index      string
+		key        string
+		filter     string
+		scan       bool
+		boundaries []string
+	}{
+		{"partition key", &orderFilter{CustomerId: "c1"}, "", "customerId = :0", "", false, nil},
+		{"partition and filter", &orderFilter{CustomerId: "c1", Status: "paid"}, "", "customerId = :1", "status = :0", false, nil},
+		{"no partition key", &orderFilter{Status: "paid"}, "", "", "status = :0", true, nil},
+		{"time range with end only", &orderFilter{CustomerId: "c1", CreatedAt: &search.TimeRange{EndTime: &end}}, "", "(customerId = :0) AND (createdAt < :1)", "", false, nil},
+		{"time range with start only", &orderFilter{CustomerId: "c1", CreatedAt: &search.TimeRange{StartTime: &start}}, "", "(customerId = :0) AND (createdAt >= :1)", "", false, nil},
+		{"time range with exclusive end", &orderFilter{CustomerId: "c1", CreatedAt: &search.TimeRange{StartTime: &start, EndTime: &end}}, "", "(customerId = :0) AND (createdAt BETWEEN :1 AND :2)", "", false, []string{"createdAt 2026-02-01T00:00:00Z"}},
+		{"inclusive number range", &orderFilter{CustomerId: "c1", Amount: &search.NumberRange{Min: &low, Max: &high}}, "byAmount", "(customerId = :0) AND (amount BETWEEN :1 AND :2)", "", false, nil},
+		{"exclusive number range", &orderFilter{CustomerId: "c1", Amount: &search.NumberRange{Lower: &low, Upper: &high}}, "byAmount", "(customerId = :0) AND (amount BETWEEN :1 AND :2)", "", false, []string{"amount 10", "amount 20"}},
+		{"exclusive upper bound", &orderFilter{CustomerId: "c1", Amount: &search.NumberRange{Min: &low, Upper: &high}}, "byAmount", "(customerId = :0) AND (amount BETWEEN :1 AND :2)", "", false, []string{"amount 20"}},
+		{"exclusive lower bound only", &orderFilter{CustomerId: "c1", Amount: &search.NumberRange{Lower: &low}}, "byAmount", "(customerId = :0) AND (amount > :1)", "", false, nil},
+		{"date range", &orderDateFilter{CustomerId: "c1", CreatedAt: &search.DateRange{Min: &start, Max: &end}}, "", "(customerId = :0) AND (createdAt BETWEEN :1 AND :2)", "", false, []string{"createdAt 2026-02-02T00:00:00Z"}},
+		{"exclusive range and a filter", &orderFilter{CustomerId: "c1", Status: "paid", Amount: &search.NumberRange{Lower: &low, Upper: &high}}, "byAmount", "(customerId = :1) AND (amount BETWEEN :2 AND :3)", "status = :0", false, []string{"amount 10", "amount 20"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilderWithIndexes("orders", reflect.TypeOf(order{}), orderIndexes...)
+			e, err := b.Explain(tt.sm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Scan != tt.scan || e.IndexName != tt.index {
+				t.Errorf("index = %q, scan = %v, want %q, %v", e.IndexName, e.Scan, tt.index, tt.scan)
+			}
+			if e.KeyCondition != tt.key {
+				t.Errorf("key condition = %q, want %q", e.KeyCondition, tt.key)
+			}
+			if e.Filter != tt.filter {
+				t.Errorf("filter = %q, want %q", e.Filter, tt.filter)
+			}
+			var boundaries []string
+			for _, boundary := range e.Boundaries {
+				boundaries = append(boundaries, boundary.Name+" "+aws.StringValue(boundary.Value.S)+aws.StringValue(boundary.Value.N))
+			}
+			if !reflect.DeepEqual(boundaries, tt.boundaries) {
+				t.Errorf("boundaries = %v, want %v", boundaries, tt.boundaries)
+			}
+		})
+	}
+}
+
+func TestBuildQueryInputWithoutIndexes(t *testing.T) {
+	end := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, -1, 0)
+	b := NewBuilder("orders", reflect.TypeOf(order{}), d.SecondaryIndex{Keys: []string{"customerId", "createdAt"}})
+	sm := &orderFilter{CustomerId: "c1", CreatedAt: &search.TimeRange{StartTime: &start, EndTime: &end}}
+	query, err := b.BuildQueryInput(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.KeyConditionExpression == nil || query.FilterExpression != nil {
+		t.Errorf("key condition = %v, filter = %v, want the range in the key condition", query.KeyConditionExpression, query.FilterExpression)
+	}
+	boundaries, err := b.BuildBoundaries(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boundaries) != 1 || boundaries[0].Name != "createdAt" {
+		t.Errorf("boundaries = %v, want the exclusive end", boundaries)
+	}
+}
+
+type orderGroupFilter struct {
+	CustomerId string
+	Status     string  `group:"paidOrLarge"`
+	Amount     float64 `group:"paidOrLarge" operator:"gte"`
+}
+
+type orderNotFilter struct {
+	CustomerId string `not:"true"`
+	Status     string
+}
+
+type orderBetweenFilter struct {
+	CustomerId string
+	Amount     []float64 `operator:"between"`
+}
+
+type orderExistsFilter struct {
+	CustomerId string
+	Status     *bool `operator:"exists"`
+}
+
+func TestBuildQueryInputConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		sm     interface{}
+		key    string
+		filter string
+		scan   bool
+		err    bool
+	}{
+		{"or group", &orderGroupFilter{CustomerId: "c1", Status: "paid", Amount: 100}, "customerId = :2", "(status = :0) OR (amount >= :1)", false, false},
+		{"negated equal on the partition key", &orderNotFilter{CustomerId: "c1", Status: "paid"}, "", "(NOT (customerId = :0)) AND (status = :1)", true, false},
+		{"between with 3 values", &orderBetweenFilter{CustomerId: "c1", Amount: []float64{10, 20, 30}}, "", "", false, true},
+		{"between with 1 value", &orderBetweenFilter{CustomerId: "c1", Amount: []float64{10}}, "", "", false, true},
+		{"exists of a false *bool", &orderExistsFilter{CustomerId: "c1", Status: aws.Bool(false)}, "customerId = :0", "attribute_not_exists (status)", false, false},
+		{"exists of a true *bool", &orderExistsFilter{CustomerId: "c1", Status: aws.Bool(true)}, "customerId = :0", "attribute_exists (status)", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilderWithIndexes("orders", reflect.TypeOf(order{}), orderIndexes...)
+			e, err := b.Explain(tt.sm)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want an error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if e.Scan != tt.scan {
+				t.Errorf("scan = %v, want %v", e.Scan, tt.scan)
+			}
+			if e.KeyCondition != tt.key {
+				t.Errorf("key condition = %q, want %q", e.KeyCondition, tt.key)
+			}
+			if e.Filter != tt.filter {
+				t.Errorf("filter = %q, want %q", e.Filter, tt.filter)
+			}
+		})
+	}
+}
+
+type product struct {
+	Id          string `json:"id" dynamodbav:"id"`
+	Name        string `json:"name" dynamodbav:"name" normalize:"true"`
+	Code        string `json:"code" dynamodbav:"code"`
+	Description string `json:"description" dynamodbav:"description"`
+}
+
+type productFilter struct {
+	*search.Filter
+	Name        string `keyword:"contain"`
+	Code        string `keyword:"prefix"`
+	Description string `keyword:"contain" lower:"descriptionLower"`
+}
+
+type productCodeFilter struct {
+	*search.Filter
+	Code string `keyword:"suffix"`
+}
+
+func TestBuildKeywordCondition(t *testing.T) {
+	tests := []struct {
+		name   string
+		sm     interface{}
+		filter string
+		values []string
+		err    bool
+	}{
+		{"keyword fields", &productFilter{Filter: &search.Filter{Q: " Crème Brûlée "}}, "((contains (nameNormalized, :0)) OR (begins_with (code, :1))) OR (contains (descriptionLower, :2))", []string{"creme brulee", "Crème Brûlée", "crème brûlée"}, false},
+		{"no keyword", &productFilter{Filter: &search.Filter{Q: "  "}}, "", nil, false},
+		{"unsupported format", &productCodeFilter{Filter: &search.Filter{Q: "A1"}}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilder("products", reflect.TypeOf(product{}), d.SecondaryIndex{Keys: []string{"id"}})
+			e, err := b.Explain(tt.sm)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want an error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if e.Filter != tt.filter {
+				t.Errorf("filter = %q, want %q", e.Filter, tt.filter)
+			}
+			query, _ := b.BuildQueryInput(tt.sm)
+			var values []string
+			for i := range tt.values {
+				values = append(values, aws.StringValue(query.ExpressionAttributeValues[":"+strconv.Itoa(i)].S))
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("values = %q, want %q", values, tt.values)
+			}
+		})
+	}
+}
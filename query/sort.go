@@ -0,0 +1,78 @@
+package query
+
+import (
+	"fmt"
+	d "github.com/core-go/dynamodb"
+	"github.com/core-go/search"
+	"reflect"
+	"strings"
+)
+
+// BuildSort returns the sort of the search model which is done client side, it is empty if the Query sorts by the sort key of the index.
+func (b *Builder) BuildSort(sm interface{}) ([]d.Sort, error) {
+	_, _, sorts, _, err := buildQueryInput(sm, b.ModelType, b.TableName, b.indexes())
+	return sorts, err
+}
+
+func getFilter(sm interface{}) *search.Filter {
+	if filter, ok := sm.(*search.Filter); ok {
+		return filter
+	}
+	value := reflect.Indirect(reflect.ValueOf(sm))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < value.NumField(); i++ {
+		if filter, ok := value.Field(i).Interface().(*search.Filter); ok && filter != nil {
+			return filter
+		}
+	}
+	return nil
+}
+
+// buildSorts parses the sort of the search model, such as "name,-age" or "name asc,age desc", with the json names of the fields.
+// The empty items, such as a sign without name, are skipped.
+func buildSorts(sm interface{}, modelType reflect.Type) ([]d.Sort, error) {
+	filter := getFilter(sm)
+	if filter == nil || len(strings.TrimSpace(filter.Sort)) == 0 {
+		return nil, nil
+	}
+	var sorts []d.Sort
+	for _, s := range strings.Split(filter.Sort, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		desc := false
+		if strings.HasPrefix(s, "-") {
+			desc = true
+			s = s[1:]
+		} else if strings.HasPrefix(s, "+") {
+			s = s[1:]
+		}
+		parts := strings.Fields(s)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) > 1 && strings.ToUpper(parts[1]) == "DESC" {
+			desc = true
+		}
+		name, ok := sortName(modelType, parts[0])
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by unknown field %s", parts[0])
+		}
+		sorts = append(sorts, d.Sort{Name: name, Desc: desc})
+	}
+	return sorts, nil
+}
+
+func sortName(modelType reflect.Type, field string) (string, bool) {
+	if i, _, ok := d.GetFieldByTagName(modelType, field); ok {
+		_, name, _ := d.GetFieldByIndex(modelType, i)
+		return name, true
+	}
+	if _, name, ok := d.GetFieldByName(modelType, field); ok {
+		return name, true
+	}
+	return "", false
+}
@@ -0,0 +1,41 @@
+package query
+
+import (
+	d "github.com/core-go/dynamodb"
+	"github.com/core-go/search"
+	"reflect"
+	"testing"
+)
+
+func TestBuildSorts(t *testing.T) {
+	tests := []struct {
+		sort    string
+		want    []d.Sort
+		invalid bool
+	}{
+		{"", nil, false},
+		{" ", nil, false},
+		{"-", nil, false},
+		{"+", nil, false},
+		{",", nil, false},
+		{"- ", nil, false},
+		{"-,x", nil, true},
+		{"-,amount", []d.Sort{{Name: "amount"}}, false},
+		{"amount", []d.Sort{{Name: "amount"}}, false},
+		{"+amount,-createdAt", []d.Sort{{Name: "amount"}, {Name: "createdAt", Desc: true}}, false},
+		{"amount asc, createdAt DESC", []d.Sort{{Name: "amount"}, {Name: "createdAt", Desc: true}}, false},
+		{"Amount", []d.Sort{{Name: "amount"}}, false},
+		{"unknown", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			sorts, err := buildSorts(&orderFilter{Filter: &search.Filter{Sort: tt.sort}}, reflect.TypeOf(order{}))
+			if (err != nil) != tt.invalid {
+				t.Fatalf("buildSorts(%q) error = %v, want error %v", tt.sort, err, tt.invalid)
+			}
+			if !tt.invalid && !reflect.DeepEqual(sorts, tt.want) {
+				t.Errorf("buildSorts(%q) = %v, want %v", tt.sort, sorts, tt.want)
+			}
+		})
+	}
+}
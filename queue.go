@@ -0,0 +1,314 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"sort"
+	"time"
+)
+
+const (
+	JobReady   = "ready"
+	JobRunning = "running"
+	JobDead    = "dead"
+)
+
+var ErrJobNotHeld = errors.New("job is not held with this receipt")
+
+// Job is an item of the queue table, whose key is id. The queue needs a GSI with the partition key queue and the sort key runAt.
+// Queue is the status and the priority of the job, such as "ready#1", so that each priority is a partition of the index.
+// RunAt is the time in epoch milliseconds when a ready job can run, or when the visibility of a running job expires.
+type Job struct {
+	Id        string    `json:"id" dynamodbav:"id"`
+	Payload   []byte    `json:"payload,omitempty" dynamodbav:"payload,omitempty"`
+	Priority  int       `json:"priority" dynamodbav:"priority"`
+	Status    string    `json:"status" dynamodbav:"status"`
+	Queue     string    `json:"queue" dynamodbav:"queue"`
+	RunAt     int64     `json:"runAt" dynamodbav:"runAt"`
+	Attempts  int       `json:"attempts" dynamodbav:"attempts"`
+	Receipt   string    `json:"receipt,omitempty" dynamodbav:"receipt,omitempty"`
+	LastError string    `json:"lastError,omitempty" dynamodbav:"lastError,omitempty"`
+	CreatedAt time.Time `json:"createdAt" dynamodbav:"createdAt"`
+}
+
+type Queue struct {
+	Database   *dynamodb.DynamoDB
+	tableName  string
+	indexName  string
+	Visibility time.Duration
+	// MaxAttempts is the number of attempts after which a failed or timed out job is dead.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// Priorities are the priorities of the jobs, highest first.
+	Priorities []int
+	Now        func() time.Time
+}
+
+// NewQueue creates a queue whose jobs have one of the priorities, 0 if there is none.
+func NewQueue(db *dynamodb.DynamoDB, tableName string, indexName string, visibility time.Duration, priorities ...int) *Queue {
+	if len(priorities) == 0 {
+		priorities = []int{0}
+	}
+	priorities = append([]int(nil), priorities...)
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+	return &Queue{Database: db, tableName: tableName, indexName: indexName, Visibility: visibility, MaxAttempts: 5, MinBackoff: time.Second, MaxBackoff: 10 * time.Minute, Priorities: priorities}
+}
+
+func queueKey(status string, priority int) string {
+	return fmt.Sprintf("%s#%d", status, priority)
+}
+
+// setStatus sets the status of the job and its partition of the index.
+func setStatus(update expression.UpdateBuilder, status string, priority int) expression.UpdateBuilder {
+	return update.Set(expression.Name("status"), expression.Value(status)).
+		Set(expression.Name("queue"), expression.Value(queueKey(status, priority)))
+}
+
+func (q *Queue) now() time.Time {
+	if q.Now != nil {
+		return q.Now()
+	}
+	return time.Now()
+}
+
+func (q *Queue) keyMap(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}}
+}
+
+func randomId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Enqueue adds a job which can run after the delay and returns its id; a due job of higher priority runs before the due jobs of lower priorities.
+func (q *Queue) Enqueue(ctx context.Context, payload []byte, delay time.Duration, priority int) (string, error) {
+	if !q.hasPriority(priority) {
+		return "", fmt.Errorf("%d is not a priority of the queue", priority)
+	}
+	id, err := randomId()
+	if err != nil {
+		return "", err
+	}
+	now := q.now()
+	job := Job{Id: id, Payload: payload, Priority: priority, Status: JobReady, Queue: queueKey(JobReady, priority), RunAt: toMillis(now.Add(delay)), CreatedAt: now}
+	item, err := dynamodbattribute.MarshalMap(job)
+	if err != nil {
+		return "", err
+	}
+	_, err = putItem(ctx, q.Database, q.tableName, item, keyNotExists([]string{"id"}), insertFailed, WriteOptions{})
+	return id, err
+}
+
+func (q *Queue) hasPriority(priority int) bool {
+	for _, p := range q.Priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// Dequeue claims up to n due jobs, ready jobs and running jobs whose visibility has expired, for the visibility timeout.
+// The jobs of a higher priority are claimed first, the oldest first. A timed out job which has reached MaxAttempts becomes dead instead.
+func (q *Queue) Dequeue(ctx context.Context, n int) ([]Job, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	now := q.now()
+	var jobs []Job
+	for _, priority := range q.Priorities {
+		// The index is eventually consistent, some jobs may have been claimed already, so more jobs than needed are read.
+		window := int64((n - len(jobs)) * 2)
+		ready, err := q.due(ctx, queueKey(JobReady, priority), now, window)
+		if err != nil {
+			return jobs, err
+		}
+		expired, err := q.due(ctx, queueKey(JobRunning, priority), now, window)
+		if err != nil {
+			return jobs, err
+		}
+		candidates := append(ready, expired...)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].RunAt < candidates[j].RunAt
+		})
+		for _, candidate := range candidates {
+			if len(jobs) >= n {
+				return jobs, nil
+			}
+			if candidate.Status == JobRunning && candidate.Attempts >= q.MaxAttempts {
+				if er1 := q.bury(ctx, candidate, "visibility timeout"); er1 != nil && !IsConditionalCheckFailed(er1) {
+					return jobs, er1
+				}
+				continue
+			}
+			job, ok, er2 := q.claim(ctx, candidate, now)
+			if er2 != nil {
+				return jobs, er2
+			}
+			if ok {
+				jobs = append(jobs, job)
+			}
+		}
+		if len(jobs) >= n {
+			break
+		}
+	}
+	return jobs, nil
+}
+
+func (q *Queue) due(ctx context.Context, queue string, now time.Time, limit int64) ([]Job, error) {
+	keyCondition := expression.Key("queue").Equal(expression.Value(queue)).
+		And(expression.Key("runAt").LessThanEqual(expression.Value(toMillis(now))))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, err
+	}
+	output, err := q.Database.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(q.tableName),
+		IndexName:                 aws.String(q.indexName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	err = dynamodbattribute.UnmarshalListOfMaps(output.Items, &jobs)
+	return jobs, err
+}
+
+// jobUnchanged is true if the job has not been claimed or updated since it was read.
+func jobUnchanged(job Job) expression.ConditionBuilder {
+	return expression.Name("status").Equal(expression.Value(job.Status)).
+		And(expression.Name("runAt").Equal(expression.Value(job.RunAt)))
+}
+
+func (q *Queue) claim(ctx context.Context, job Job, now time.Time) (Job, bool, error) {
+	receipt, err := randomId()
+	if err != nil {
+		return job, false, err
+	}
+	update := setStatus(expression.UpdateBuilder{}, JobRunning, job.Priority).
+		Set(expression.Name("runAt"), expression.Value(toMillis(now.Add(q.Visibility)))).
+		Set(expression.Name("receipt"), expression.Value(receipt)).
+		Add(expression.Name("attempts"), expression.Value(1))
+	condition := jobUnchanged(job)
+	var claimed Job
+	_, err = updateItem(ctx, q.Database, q.tableName, q.keyMap(job.Id), update, &condition, conditionFailed, WriteOptions{ReturnValues: dynamodb.ReturnValueAllNew, Result: &claimed})
+	if err != nil {
+		if IsConditionalCheckFailed(err) {
+			return job, false, nil
+		}
+		return job, false, err
+	}
+	return claimed, true, nil
+}
+
+func (q *Queue) bury(ctx context.Context, job Job, reason string) error {
+	update := setStatus(expression.UpdateBuilder{}, JobDead, job.Priority).
+		Set(expression.Name("runAt"), expression.Value(toMillis(q.now()))).
+		Set(expression.Name("lastError"), expression.Value(reason)).
+		Remove(expression.Name("receipt"))
+	condition := jobUnchanged(job)
+	_, err := updateItem(ctx, q.Database, q.tableName, q.keyMap(job.Id), update, &condition, conditionFailed, WriteOptions{})
+	return err
+}
+
+func jobHeld(job Job) expression.ConditionBuilder {
+	return expression.Name("status").Equal(expression.Value(JobRunning)).
+		And(expression.Name("receipt").Equal(expression.Value(job.Receipt)))
+}
+
+func jobHeldError(err error) error {
+	if IsConditionalCheckFailed(err) {
+		return ErrJobNotHeld
+	}
+	return err
+}
+
+// Ack deletes the job once it is done.
+func (q *Queue) Ack(ctx context.Context, job Job) error {
+	condition := jobHeld(job)
+	_, err := deleteItem(ctx, q.Database, q.tableName, q.keyMap(job.Id), &condition, conditionFailed, WriteOptions{})
+	return jobHeldError(err)
+}
+
+// Nack releases the failed job to run again after an exponential backoff, or makes it dead once it has reached MaxAttempts.
+func (q *Queue) Nack(ctx context.Context, job Job, reason string) error {
+	now := q.now()
+	var update expression.UpdateBuilder
+	if job.Attempts >= q.MaxAttempts {
+		update = setStatus(update, JobDead, job.Priority).
+			Set(expression.Name("runAt"), expression.Value(toMillis(now)))
+	} else {
+		backoff := q.MinBackoff
+		for i := 1; i < job.Attempts && backoff < q.MaxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > q.MaxBackoff {
+			backoff = q.MaxBackoff
+		}
+		update = setStatus(update, JobReady, job.Priority).
+			Set(expression.Name("runAt"), expression.Value(toMillis(now.Add(backoff))))
+	}
+	update = update.Set(expression.Name("lastError"), expression.Value(reason)).Remove(expression.Name("receipt"))
+	condition := jobHeld(job)
+	_, err := updateItem(ctx, q.Database, q.tableName, q.keyMap(job.Id), update, &condition, conditionFailed, WriteOptions{})
+	return jobHeldError(err)
+}
+
+// Extend moves the visibility deadline of a running job, it must be called before the deadline for long running jobs.
+func (q *Queue) Extend(ctx context.Context, job *Job, visibility time.Duration) error {
+	runAt := toMillis(q.now().Add(visibility))
+	update := expression.Set(expression.Name("runAt"), expression.Value(runAt))
+	condition := jobHeld(*job)
+	_, err := updateItem(ctx, q.Database, q.tableName, q.keyMap(job.Id), update, &condition, conditionFailed, WriteOptions{})
+	if err != nil {
+		return jobHeldError(err)
+	}
+	job.RunAt = runAt
+	return nil
+}
+
+// Dead returns the dead jobs of all priorities, oldest first.
+func (q *Queue) Dead(ctx context.Context, limit int64) ([]Job, error) {
+	now := q.now()
+	var jobs []Job
+	for _, priority := range q.Priorities {
+		dead, err := q.due(ctx, queueKey(JobDead, priority), now, limit)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, dead...)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].RunAt < jobs[j].RunAt
+	})
+	if int64(len(jobs)) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// Retry makes a dead job ready again, with its attempts reset.
+func (q *Queue) Retry(ctx context.Context, job Job) error {
+	update := setStatus(expression.UpdateBuilder{}, JobReady, job.Priority).
+		Set(expression.Name("runAt"), expression.Value(toMillis(q.now()))).
+		Set(expression.Name("attempts"), expression.Value(0))
+	dead := expression.Name("status").Equal(expression.Value(JobDead))
+	_, err := updateItem(ctx, q.Database, q.tableName, q.keyMap(job.Id), update, &dead, updateFailed, WriteOptions{})
+	return err
+}
@@ -0,0 +1,189 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+// queueIndex answers the queries of the index from the jobs and returns the stored job of a claim.
+func queueIndex(t *testing.T, jobs []Job) func(r fakeRequest) interface{} {
+	return func(r fakeRequest) interface{} {
+		switch r.Operation {
+		case "Query":
+			var input dynamodb.QueryInput
+			r.Decode(t, &input)
+			var queue string
+			var now int64
+			for _, v := range input.ExpressionAttributeValues {
+				if v.S != nil {
+					queue = *v.S
+				} else {
+					now = number(v)
+				}
+			}
+			var due []Job
+			for _, job := range jobs {
+				if job.Queue == queue && job.RunAt <= now {
+					due = append(due, job)
+				}
+			}
+			sort.Slice(due, func(i, j int) bool { return due[i].RunAt < due[j].RunAt })
+			if limit := aws.Int64Value(input.Limit); int64(len(due)) > limit {
+				due = due[:limit]
+			}
+			items, _ := dynamodbattribute.MarshalList(due)
+			output := &dynamodb.QueryOutput{}
+			for _, item := range items {
+				output.Items = append(output.Items, item.M)
+			}
+			return output
+		case "UpdateItem":
+			var input dynamodb.UpdateItemInput
+			r.Decode(t, &input)
+			for _, job := range jobs {
+				if job.Id == aws.StringValue(input.Key["id"].S) {
+					item, _ := dynamodbattribute.MarshalMap(job)
+					return &dynamodb.UpdateItemOutput{Attributes: item}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func job(id string, priority int, status string, runAt int64, attempts int) Job {
+	return Job{Id: id, Priority: priority, Status: status, Queue: queueKey(status, priority), RunAt: runAt, Attempts: attempts}
+}
+
+var queueSet = regexp.MustCompile(`queue = ([a-z]+#[0-9]+)`)
+
+// updates returns the id and the new queue of the updated jobs.
+func updates(t *testing.T, fake *fakeDynamoDB) []string {
+	var list []string
+	for _, r := range fake.Requests("UpdateItem") {
+		var input dynamodb.UpdateItemInput
+		r.Decode(t, &input)
+		update := expand(aws.StringValue(input.UpdateExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+		if m := queueSet.FindStringSubmatch(update); m != nil {
+			list = append(list, aws.StringValue(input.Key["id"].S)+": "+m[1])
+		}
+	}
+	return list
+}
+
+func TestQueueDequeue(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ms := toMillis(now)
+	// The ready jobs of priority 0 are older than the job of priority 2, and more than a window of a dequeue.
+	var jobs []Job
+	for _, id := range []string{"l1", "l2", "l3", "l4", "l5", "l6", "l7", "l8"} {
+		jobs = append(jobs, job(id, 0, JobReady, ms-int64(len(jobs)+10)*1000, 0))
+	}
+	jobs = append(jobs,
+		job("h1", 2, JobReady, ms-1000, 0),
+		job("h2", 2, JobReady, ms+1000, 0),
+		job("m1", 1, JobRunning, ms-2000, 1),
+		job("m2", 1, JobRunning, ms-3000, 5),
+	)
+	tests := []struct {
+		name    string
+		n       int
+		claimed []string
+		updates []string
+	}{
+		{"highest priority first", 1, []string{"h1"}, []string{"h1: running#2"}},
+		{"expired jobs of the next priority", 2, []string{"h1", "m1"}, []string{"h1: running#2", "m2: dead#1", "m1: running#1"}},
+		{"oldest jobs of the lowest priority", 4, []string{"h1", "m1", "l8", "l7"}, []string{"h1: running#2", "m2: dead#1", "m1: running#1", "l8: running#0", "l7: running#0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, queueIndex(t, jobs))
+			q := NewQueue(db, "jobs", "queue-index", time.Minute, 0, 2, 1)
+			q.Now = func() time.Time {
+				return now
+			}
+			claimed, err := q.Dequeue(context.Background(), tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, job := range claimed {
+				ids = append(ids, job.Id)
+			}
+			if !reflect.DeepEqual(ids, tt.claimed) {
+				t.Errorf("claimed = %v, want %v", ids, tt.claimed)
+			}
+			if u := updates(t, fake); !reflect.DeepEqual(u, tt.updates) {
+				t.Errorf("updates = %v, want %v", u, tt.updates)
+			}
+		})
+	}
+}
+
+func TestQueueEnqueue(t *testing.T) {
+	db, fake := newFakeDynamoDB(t, nil)
+	q := NewQueue(db, "jobs", "queue-index", time.Minute, 1, 0)
+	if !reflect.DeepEqual(q.Priorities, []int{1, 0}) {
+		t.Errorf("priorities = %v, want [1 0]", q.Priorities)
+	}
+	if _, err := q.Enqueue(context.Background(), []byte("p"), 0, 2); err == nil {
+		t.Error("Enqueue of an unknown priority returns no error")
+	}
+	if _, err := q.Enqueue(context.Background(), []byte("p"), 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	puts := fake.Requests("PutItem")
+	if len(puts) != 1 {
+		t.Fatalf("%d PutItem, want 1", len(puts))
+	}
+	var input dynamodb.PutItemInput
+	puts[0].Decode(t, &input)
+	if v := input.Item["queue"]; v == nil || aws.StringValue(v.S) != "ready#1" {
+		t.Errorf("queue = %v, want ready#1", v)
+	}
+}
+
+func TestQueueNackAndDead(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ms := toMillis(now)
+	jobs := []Job{
+		job("d1", 0, JobDead, ms-1000, 5),
+		job("d2", 1, JobDead, ms-3000, 5),
+		job("d3", 0, JobDead, ms-2000, 5),
+	}
+	db, fake := newFakeDynamoDB(t, queueIndex(t, jobs))
+	q := NewQueue(db, "jobs", "queue-index", time.Minute, 0, 1)
+	q.Now = func() time.Time {
+		return now
+	}
+	dead, err := q.Dead(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, job := range dead {
+		ids = append(ids, job.Id)
+	}
+	if !reflect.DeepEqual(ids, []string{"d2", "d3"}) {
+		t.Errorf("dead = %v, want the oldest of all priorities", ids)
+	}
+	if err := q.Retry(context.Background(), dead[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Nack(context.Background(), job("r1", 1, JobRunning, ms, 2), "failed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Nack(context.Background(), job("r2", 0, JobRunning, ms, 5), "failed"); err != nil {
+		t.Fatal(err)
+	}
+	if u := updates(t, fake); !reflect.DeepEqual(u, []string{"d2: ready#1", "r1: ready#1", "r2: dead#0"}) {
+		t.Errorf("updates = %v", u)
+	}
+}
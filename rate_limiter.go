@@ -0,0 +1,198 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	FixedWindow   = "fixed"
+	SlidingWindow = "sliding"
+	TokenBucket   = "token_bucket"
+)
+
+var ErrRateLimitConflict = errors.New("too many concurrent updates of the rate limit")
+
+// RateLimiter allows Limit units per Window. For TokenBucket, Limit is the capacity of the bucket and Window the time to refill it.
+// The window items expire with the TTL attribute, so the table must have TTL enabled on it.
+type RateLimiter struct {
+	Database  *dynamodb.DynamoDB
+	tableName string
+	keyName   string
+	ttlName   string
+	Algorithm string
+	Limit     int
+	Window    time.Duration
+	Now       func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter; options are the names of the key and of the TTL attribute.
+func NewRateLimiter(db *dynamodb.DynamoDB, tableName string, algorithm string, limit int, window time.Duration, options ...string) *RateLimiter {
+	keyName, ttlName := "id", "expiredAt"
+	if len(options) >= 1 && len(options[0]) > 0 {
+		keyName = options[0]
+	}
+	if len(options) >= 2 && len(options[1]) > 0 {
+		ttlName = options[1]
+	}
+	return &RateLimiter{Database: db, tableName: tableName, keyName: keyName, ttlName: ttlName, Algorithm: algorithm, Limit: limit, Window: window}
+}
+
+func (r *RateLimiter) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func (r *RateLimiter) keyMap(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{r.keyName: {S: aws.String(key)}}
+}
+
+// Allow consumes cost units of the key if the limit allows it, and returns the remaining units and the time when the limit resets.
+func (r *RateLimiter) Allow(ctx context.Context, key string, cost int) (bool, int, time.Time, error) {
+	if cost <= 0 {
+		cost = 1
+	}
+	switch r.Algorithm {
+	case FixedWindow, "":
+		return r.fixedWindow(ctx, key, cost)
+	case SlidingWindow:
+		return r.slidingWindow(ctx, key, cost)
+	case TokenBucket:
+		return r.tokenBucket(ctx, key, cost)
+	default:
+		return false, 0, time.Time{}, fmt.Errorf("rate limit algorithm %s is not supported", r.Algorithm)
+	}
+}
+
+func (r *RateLimiter) windowKey(key string, start time.Time) string {
+	return key + "#" + strconv.FormatInt(toMillis(start), 10)
+}
+
+func numberAttribute(item map[string]*dynamodb.AttributeValue, name string) float64 {
+	if v, ok := item[name]; ok && v.N != nil {
+		n, _ := strconv.ParseFloat(*v.N, 64)
+		return n
+	}
+	return 0
+}
+
+// add adds cost to the count of the window item if the count stays under max, and returns the count of the item.
+func (r *RateLimiter) add(ctx context.Context, key string, cost int, max int, expiredAt time.Time) (bool, int, error) {
+	if cost > max {
+		_, item, err := r.get(ctx, key)
+		return false, int(numberAttribute(item, "count")), err
+	}
+	count := expression.Name("count")
+	update := expression.Add(count, expression.Value(cost)).
+		Set(expression.Name(r.ttlName), expression.Value(expiredAt.Unix()))
+	condition := expression.AttributeNotExists(count).Or(count.LessThanEqual(expression.Value(max - cost)))
+	var result map[string]interface{}
+	_, err := updateItem(ctx, r.Database, r.tableName, r.keyMap(key), update, &condition, conditionFailed, WriteOptions{ReturnValues: dynamodb.ReturnValueUpdatedNew, ReturnValuesOnConditionCheckFailure: true, Result: &result})
+	if err != nil {
+		if e, ok := err.(*ConditionalCheckFailedError); ok {
+			return false, int(numberAttribute(e.Item, "count")), nil
+		}
+		return false, 0, err
+	}
+	n, _ := result["count"].(float64)
+	return true, int(n), nil
+}
+
+func (r *RateLimiter) get(ctx context.Context, key string) (bool, map[string]*dynamodb.AttributeValue, error) {
+	output, err := r.Database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            r.keyMap(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return len(output.Item) > 0, output.Item, nil
+}
+
+func (r *RateLimiter) fixedWindow(ctx context.Context, key string, cost int) (bool, int, time.Time, error) {
+	start := r.now().Truncate(r.Window)
+	resetAt := start.Add(r.Window)
+	allowed, count, err := r.add(ctx, r.windowKey(key, start), cost, r.Limit, resetAt.Add(r.Window))
+	if err != nil {
+		return false, 0, resetAt, err
+	}
+	return allowed, remainingUnits(r.Limit, count), resetAt, nil
+}
+
+// slidingWindow weights the count of the previous window by the part of it which is still in the sliding window.
+func (r *RateLimiter) slidingWindow(ctx context.Context, key string, cost int) (bool, int, time.Time, error) {
+	now := r.now()
+	start := now.Truncate(r.Window)
+	resetAt := start.Add(r.Window)
+	_, previous, err := r.get(ctx, r.windowKey(key, start.Add(-r.Window)))
+	if err != nil {
+		return false, 0, resetAt, err
+	}
+	weight := 1 - float64(now.Sub(start))/float64(r.Window)
+	weighted := int(math.Ceil(numberAttribute(previous, "count") * weight))
+	allowed, count, err := r.add(ctx, r.windowKey(key, start), cost, r.Limit-weighted, resetAt.Add(r.Window))
+	if err != nil {
+		return false, 0, resetAt, err
+	}
+	return allowed, remainingUnits(r.Limit, weighted+count), resetAt, nil
+}
+
+// tokenBucket refills the bucket from the time of its last update, and writes it with an optimistic lock on its version.
+func (r *RateLimiter) tokenBucket(ctx context.Context, key string, cost int) (bool, int, time.Time, error) {
+	rate := float64(r.Limit) / float64(r.Window)
+	for i := 0; i < 5; i++ {
+		now := r.now()
+		exists, item, err := r.get(ctx, key)
+		if err != nil {
+			return false, 0, now, err
+		}
+		tokens := float64(r.Limit)
+		updatedAt := toMillis(now)
+		if exists {
+			updatedAt = int64(numberAttribute(item, "updatedAt"))
+			elapsed := float64(toMillis(now)-updatedAt) * float64(time.Millisecond)
+			tokens = math.Min(float64(r.Limit), numberAttribute(item, "tokens")+elapsed*rate)
+		}
+		if tokens < float64(cost) {
+			wait := time.Duration((float64(cost) - tokens) / rate)
+			return false, int(tokens), now.Add(wait), nil
+		}
+		tokens -= float64(cost)
+		full := now.Add(time.Duration((float64(r.Limit) - tokens) / rate))
+		update := expression.Set(expression.Name("tokens"), expression.Value(tokens)).
+			Set(expression.Name("updatedAt"), expression.Value(toMillis(now))).
+			Set(expression.Name(r.ttlName), expression.Value(full.Add(r.Window).Unix())).
+			Add(expression.Name("version"), expression.Value(1))
+		var condition expression.ConditionBuilder
+		if exists {
+			condition = expression.Name("version").Equal(expression.Value(int64(numberAttribute(item, "version"))))
+		} else {
+			condition = expression.AttributeNotExists(expression.Name(r.keyName))
+		}
+		_, err = updateItem(ctx, r.Database, r.tableName, r.keyMap(key), update, &condition, conditionFailed, WriteOptions{})
+		if err == nil {
+			return true, int(tokens), full, nil
+		}
+		if !IsConditionalCheckFailed(err) {
+			return false, 0, now, err
+		}
+	}
+	return false, 0, r.now(), ErrRateLimitConflict
+}
+
+func remainingUnits(limit int, count int) int {
+	if count >= limit {
+		return 0
+	}
+	return limit - count
+}
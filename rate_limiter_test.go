@@ -0,0 +1,213 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	maxCount       = regexp.MustCompile(`count <= (-?[0-9]+)`)
+	currentVersion = regexp.MustCompile(`version = ([0-9]+)`)
+)
+
+// limitTable stores the numbers of the items of a rate limiter, and applies the SET and ADD clauses of an update if its condition holds.
+// A conflict changes the version of the item before each update, as a concurrent writer would.
+type limitTable struct {
+	mu       sync.Mutex
+	items    map[string]map[string]float64
+	conflict bool
+}
+
+func (l *limitTable) item(key string) map[string]*dynamodb.AttributeValue {
+	numbers, ok := l.items[key]
+	if !ok {
+		return nil
+	}
+	item := map[string]*dynamodb.AttributeValue{"id": {S: aws.String(key)}}
+	for name, n := range numbers {
+		item[name] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(n, 'f', -1, 64))}
+	}
+	return item
+}
+
+func (l *limitTable) holds(condition string, numbers map[string]float64, exists bool) bool {
+	if m := maxCount.FindStringSubmatch(condition); m != nil {
+		max, _ := strconv.ParseFloat(m[1], 64)
+		count, ok := numbers["count"]
+		return !ok || count <= max
+	}
+	if m := currentVersion.FindStringSubmatch(condition); m != nil {
+		version, _ := strconv.ParseFloat(m[1], 64)
+		return exists && numbers["version"] == version
+	}
+	return !exists
+}
+
+func (l *limitTable) handle(t *testing.T) func(r fakeRequest) interface{} {
+	return func(r fakeRequest) interface{} {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		switch r.Operation {
+		case "GetItem":
+			var input dynamodb.GetItemInput
+			r.Decode(t, &input)
+			return &dynamodb.GetItemOutput{Item: l.item(aws.StringValue(input.Key["id"].S))}
+		case "UpdateItem":
+			var input dynamodb.UpdateItemInput
+			r.Decode(t, &input)
+			key := aws.StringValue(input.Key["id"].S)
+			numbers, exists := l.items[key]
+			if exists && l.conflict {
+				numbers["version"]++
+			}
+			condition := expand(aws.StringValue(input.ConditionExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+			if !l.holds(condition, numbers, exists) {
+				return fakeError{Code: dynamodb.ErrCodeConditionalCheckFailedException, Item: l.item(key)}
+			}
+			if !exists {
+				numbers = make(map[string]float64)
+				l.items[key] = numbers
+			}
+			update := expand(aws.StringValue(input.UpdateExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+			for _, clause := range strings.Split(update, "\n") {
+				switch {
+				case strings.HasPrefix(clause, "ADD "):
+					fields := strings.Fields(clause)
+					n, _ := strconv.ParseFloat(fields[2], 64)
+					numbers[fields[1]] += n
+				case strings.HasPrefix(clause, "SET "):
+					for _, assignment := range strings.Split(clause[4:], ", ") {
+						parts := strings.Split(assignment, " = ")
+						numbers[parts[0]], _ = strconv.ParseFloat(parts[1], 64)
+					}
+				}
+			}
+			return &dynamodb.UpdateItemOutput{Attributes: l.item(key)}
+		}
+		return nil
+	}
+}
+
+type allowCall struct {
+	after     time.Duration
+	cost      int
+	allowed   bool
+	remaining int
+	resetAt   time.Duration
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Unix(1699999980, 0)
+	tests := []struct {
+		name      string
+		algorithm string
+		previous  float64
+		calls     []allowCall
+	}{
+		{"fixed window", FixedWindow, 0, []allowCall{
+			{0, 3, true, 7, time.Minute},
+			{10 * time.Second, 7, true, 0, time.Minute},
+			{20 * time.Second, 1, false, 0, time.Minute},
+			{time.Minute, 11, false, 10, 2 * time.Minute},
+			{time.Minute, 10, true, 0, 2 * time.Minute},
+		}},
+		// The 6 units of the previous window weigh 5 at a quarter of the window and 2 at three quarters, then the 8 units of the window weigh 8.
+		{"sliding window", SlidingWindow, 6, []allowCall{
+			{15 * time.Second, 3, true, 2, time.Minute},
+			{15 * time.Second, 3, false, 2, time.Minute},
+			{45 * time.Second, 5, true, 0, time.Minute},
+			{time.Minute, 5, false, 2, 2 * time.Minute},
+		}},
+		// The bucket of 10 tokens refills at one token per second.
+		{"token bucket", TokenBucket, 0, []allowCall{
+			{0, 4, true, 6, 4 * time.Second},
+			{2 * time.Second, 8, true, 0, 12 * time.Second},
+			{2 * time.Second, 1, false, 0, 3 * time.Second},
+			{time.Minute, 10, true, 0, time.Minute + 10*time.Second},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &limitTable{items: make(map[string]map[string]float64)}
+			if tt.previous > 0 {
+				table.items["k#"+strconv.FormatInt(toMillis(start.Add(-time.Minute)), 10)] = map[string]float64{"count": tt.previous}
+			}
+			db, _ := newFakeDynamoDB(t, table.handle(t))
+			limiter := NewRateLimiter(db, "limits", tt.algorithm, 10, time.Minute)
+			if tt.algorithm == TokenBucket {
+				limiter.Window = 10 * time.Second
+			}
+			for i, call := range tt.calls {
+				now := start.Add(call.after)
+				limiter.Now = func() time.Time {
+					return now
+				}
+				allowed, remaining, resetAt, err := limiter.Allow(context.Background(), "k", call.cost)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if allowed != call.allowed || remaining != call.remaining {
+					t.Errorf("call %d: Allow = %v, %d, want %v, %d", i, allowed, remaining, call.allowed, call.remaining)
+				}
+				if d := resetAt.Sub(start.Add(call.resetAt)); d < -time.Millisecond || d > time.Millisecond {
+					t.Errorf("call %d: resetAt = %v, want %v", i, resetAt, start.Add(call.resetAt))
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterWindowExpiry(t *testing.T) {
+	start := time.Unix(1699999980, 0)
+	table := &limitTable{items: make(map[string]map[string]float64)}
+	db, _ := newFakeDynamoDB(t, table.handle(t))
+	limiter := NewRateLimiter(db, "limits", FixedWindow, 10, time.Minute)
+	limiter.Now = func() time.Time {
+		return start.Add(30 * time.Second)
+	}
+	if _, _, _, err := limiter.Allow(context.Background(), "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	window, ok := table.items["k#"+strconv.FormatInt(toMillis(start), 10)]
+	if !ok {
+		t.Fatalf("items = %v, want the item of the window", table.items)
+	}
+	// The item is kept during the next window, which reads it as the previous window of a sliding window.
+	if expiredAt := int64(window["expiredAt"]); expiredAt != start.Add(2*time.Minute).Unix() {
+		t.Errorf("expiredAt = %d, want the end of the next window", expiredAt)
+	}
+}
+
+func TestRateLimiterTokenBucketConflict(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	table := &limitTable{items: map[string]map[string]float64{"k": {"tokens": 10, "updatedAt": float64(toMillis(now)), "version": 1}}, conflict: true}
+	db, fake := newFakeDynamoDB(t, table.handle(t))
+	limiter := NewRateLimiter(db, "limits", TokenBucket, 10, 10*time.Second)
+	limiter.Now = func() time.Time {
+		return now
+	}
+	allowed, _, _, err := limiter.Allow(context.Background(), "k", 1)
+	if err != ErrRateLimitConflict || allowed {
+		t.Fatalf("Allow = %v, %v, want %v", allowed, err, ErrRateLimitConflict)
+	}
+	if gets, updates := len(fake.Requests("GetItem")), len(fake.Requests("UpdateItem")); gets != 5 || updates != 5 {
+		t.Errorf("%d GetItem and %d UpdateItem, want 5 attempts", gets, updates)
+	}
+	if tokens := table.items["k"]["tokens"]; tokens != 10 {
+		t.Errorf("tokens = %v, want the bucket unchanged", tokens)
+	}
+}
+
+func TestRateLimiterUnknownAlgorithm(t *testing.T) {
+	limiter := NewRateLimiter(nil, "limits", "leaky", 10, time.Minute)
+	if _, _, _, err := limiter.Allow(context.Background(), "k", 1); err == nil {
+		t.Error("Allow returns no error for an unknown algorithm")
+	}
+}
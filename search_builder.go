@@ -2,15 +2,30 @@ package dynamodb
 
 import (
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"reflect"
 )
 
 type SearchBuilder struct {
-	DB         *dynamodb.DynamoDB
-	ModelType  reflect.Type
+	DB        *dynamodb.DynamoDB
+	ModelType reflect.Type
+	// BuildQuery builds a Scan, it is only used if BuildQueryInput is nil.
 	BuildQuery func(m interface{}) (dynamodb.ScanInput, error)
 	Map        func(ctx context.Context, model interface{}) (interface{}, error)
+	SoftDelete *SoftDelete
+	// BuildQueryInput builds a Query when the search model has the keys of an index, and a QueryInput without key condition for a Scan otherwise.
+	BuildQueryInput func(m interface{}) (dynamodb.QueryInput, error)
+	// BuildSort returns the sort which is done client side, on at most MaxSortItems results.
+	BuildSort    func(m interface{}) ([]Sort, error)
+	MaxSortItems int64
+	// BuildBoundaries returns the exclusive bounds of the key condition, whose items are dropped client side.
+	BuildBoundaries func(m interface{}) ([]Boundary, error)
+	// Count is CountExact, CountEstimate or CountNone (total is -1), the total is the number of items of the page if it is empty.
+	Count         string
+	CountSegments int
+	CountCache    *CountCache
 }
 
 func NewSearchBuilder(db *dynamodb.DynamoDB, modelType reflect.Type, buildQuery func(interface{}) (dynamodb.ScanInput, error), options ...func(context.Context, interface{}) (interface{}, error)) *SearchBuilder {
@@ -20,25 +35,94 @@ func NewSearchBuilder(db *dynamodb.DynamoDB, modelType reflect.Type, buildQuery
 	}
 	return &SearchBuilder{DB: db, ModelType: modelType, BuildQuery: buildQuery, Map: mp}
 }
-func (b *SearchBuilder) Search(ctx context.Context, m interface{}, results interface{}, limit int64, options ...int64) (int64, string, error) {
-	query, er1 := b.BuildQuery(m)
-	if er1 != nil {
-		return 0, "", er1
+func NewQuerySearchBuilder(db *dynamodb.DynamoDB, modelType reflect.Type, buildQuery func(interface{}) (dynamodb.QueryInput, error), options ...func(context.Context, interface{}) (interface{}, error)) *SearchBuilder {
+	var mp func(ctx context.Context, model interface{}) (interface{}, error)
+	if len(options) > 0 && options[0] != nil {
+		mp = options[0]
 	}
+	return &SearchBuilder{DB: db, ModelType: modelType, BuildQueryInput: buildQuery, Map: mp, MaxSortItems: 1000}
+}
+func (b *SearchBuilder) Search(ctx context.Context, m interface{}, results interface{}, limit int64, options ...int64) (int64, string, error) {
 	var skip int64 = 0
 	if len(options) > 0 && options[0] > 0 {
 		skip = options[0]
 	}
-	if skip == 0 {
-		total, er2 := BuildSearchResult(ctx, b.DB, results, query, limit, 1, b.Map)
-		return total, "", er2
-	} else {
-		pageIndex := skip / limit
-		m := skip % limit
-		if m > 0 {
-			pageIndex = pageIndex + 1
+	return b.search(ctx, m, results, limit, skip, nil)
+}
+
+// SearchWithNextPageToken returns the page after the last item of the previous page, and the token of the next page, which is empty at the end.
+func (b *SearchBuilder) SearchWithNextPageToken(ctx context.Context, m interface{}, results interface{}, limit int64, nextPageToken string) (int64, string, error) {
+	startKey, err := DecodeStartKey(nextPageToken)
+	if err != nil {
+		return 0, "", err
+	}
+	return b.search(ctx, m, results, limit, 0, startKey)
+}
+
+func (b *SearchBuilder) search(ctx context.Context, m interface{}, results interface{}, limit int64, skip int64, startKey map[string]*dynamodb.AttributeValue) (int64, string, error) {
+	query, er1 := b.buildQueryInput(m)
+	if er1 != nil {
+		return 0, "", er1
+	}
+	var boundaries []Boundary
+	if b.BuildBoundaries != nil {
+		if boundaries, er1 = b.BuildBoundaries(m); er1 != nil {
+			return 0, "", er1
+		}
+	}
+	if b.BuildSort != nil {
+		sorts, er2 := b.BuildSort(m)
+		if er2 != nil {
+			return 0, "", er2
+		}
+		if len(sorts) > 0 {
+			if startKey != nil {
+				return 0, "", fmt.Errorf("next page token is not supported with a client side sort")
+			}
+			var pageIndex int64 = 1
+			if limit > 0 {
+				pageIndex = skip/limit + 1
+			}
+			total, er3 := buildSortedResult(ctx, b.DB, results, query, boundaries, sorts, b.MaxSortItems, limit, pageIndex, b.Map)
+			return total, "", er3
+		}
+	}
+	query.ExclusiveStartKey = startKey
+	items, lastKey, er2 := ReadPage(ctx, b.DB, query, skip, limit, boundaries...)
+	if er2 != nil {
+		return 0, "", er2
+	}
+	if er2 = dynamodbattribute.UnmarshalListOfMaps(items, results); er2 != nil {
+		return 0, "", er2
+	}
+	if b.Map != nil {
+		if _, er2 = MapModels(ctx, results, b.Map); er2 != nil {
+			return 0, "", er2
 		}
-		total, er2 := BuildSearchResult(ctx, b.DB, results, query, limit, pageIndex, b.Map)
-		return total, "", er2
 	}
+	total := int64(len(items))
+	if len(b.Count) > 0 {
+		if total, er2 = Count(ctx, b.DB, query, b.Count, b.CountSegments, b.CountCache, boundaries...); er2 != nil {
+			return 0, "", er2
+		}
+	}
+	next, er3 := EncodeStartKey(lastKey)
+	return total, next, er3
+}
+
+func (b *SearchBuilder) buildQueryInput(m interface{}) (dynamodb.QueryInput, error) {
+	if b.BuildQueryInput != nil {
+		query, err := b.BuildQueryInput(m)
+		if err != nil {
+			return query, err
+		}
+		ExcludeDeletedFromQuery(&query, b.SoftDelete)
+		return query, nil
+	}
+	scan, err := b.BuildQuery(m)
+	if err != nil {
+		return dynamodb.QueryInput{}, err
+	}
+	ExcludeDeleted(&scan, b.SoftDelete)
+	return ToQueryInput(&scan), nil
 }
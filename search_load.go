@@ -6,7 +6,7 @@ import (
 	"reflect"
 )
 
-func NewSearchLoaderWithQuery(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, buildQuery func(interface{}) (dynamodb.ScanInput, error), options ...func(context.Context, interface{}) (interface{}, error)) (*Searcher, *Loader) {
+func NewSearchLoaderWithQuery(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, buildQuery func(interface{}) (dynamodb.QueryInput, error), options ...func(context.Context, interface{}) (interface{}, error)) (*Searcher, *Loader) {
 	loader := NewLoader(db, tableName, modelType, partitionKeyName, sortKeyName)
 	searcher := NewSearcherWithQuery(db, modelType, buildQuery, options...)
 	return searcher, loader
@@ -6,10 +6,10 @@ import (
 	"reflect"
 )
 
-func NewSearchWriter(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, buildQuery func(interface{}) (dynamodb.ScanInput, error), options ...Mapper) (*Searcher, *Writer) {
+func NewSearchWriter(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, buildQuery func(interface{}) (dynamodb.QueryInput, error), options ...Mapper) (*Searcher, *Writer) {
 	return NewSearchWriterWithVersionAndQuery(db, tableName, modelType, partitionKeyName, sortKeyName, "", buildQuery, options...)
 }
-func NewSearchWriterWithVersionAndQuery(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, versionField string, buildQuery func(interface{}) (dynamodb.ScanInput, error), options ...Mapper) (*Searcher, *Writer) {
+func NewSearchWriterWithVersionAndQuery(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, versionField string, buildQuery func(interface{}) (dynamodb.QueryInput, error), options ...Mapper) (*Searcher, *Writer) {
 	var mapper Mapper
 	if len(options) > 0 && options[0] != nil {
 		mapper = options[0]
@@ -24,6 +24,14 @@ func NewSearchWriterWithVersionAndQuery(db *dynamodb.DynamoDB, tableName string,
 		return searcher, writer
 	}
 }
+func NewSearchWriterWithSoftDelete(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, versionField string, softDelete *SoftDelete, buildQuery func(interface{}) (dynamodb.QueryInput, error), options ...Mapper) (*Searcher, *Writer) {
+	writer := NewWriterWithVersion(db, tableName, modelType, partitionKeyName, sortKeyName, versionField, options...)
+	writer.SoftDelete = softDelete
+	if len(options) > 0 && options[0] != nil {
+		return NewSearcherWithSoftDelete(db, modelType, buildQuery, softDelete, options[0].DbToModel), writer
+	}
+	return NewSearcherWithSoftDelete(db, modelType, buildQuery, softDelete), writer
+}
 func NewSearchWriterWithVersion(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, versionField string, search func(context.Context, interface{}, interface{}, int64, ...int64) (int64, string, error)) (*Searcher, *Writer) {
 	writer := NewWriterWithVersion(db, tableName, modelType, partitionKeyName, sortKeyName, versionField)
 	searcher := NewSearcher(search)
@@ -10,8 +10,33 @@ type Searcher struct {
 	search func(ctx context.Context, searchModel interface{}, results interface{}, limit int64, options ...int64) (int64, string, error)
 }
 
-func NewSearcherWithQuery(db *dynamodb.DynamoDB, modelType reflect.Type, buildQuery func(interface{}) (dynamodb.ScanInput, error), options ...func(context.Context, interface{}) (interface{}, error)) *Searcher {
-	builder := NewSearchBuilder(db, modelType, buildQuery, options...)
+// NewSearcherWithQuery searches with the QueryInput of buildQuery, which is a Query if it has a key condition and a Scan otherwise.
+func NewSearcherWithQuery(db *dynamodb.DynamoDB, modelType reflect.Type, buildQuery func(interface{}) (dynamodb.QueryInput, error), options ...func(context.Context, interface{}) (interface{}, error)) *Searcher {
+	builder := NewQuerySearchBuilder(db, modelType, buildQuery, options...)
+	return NewSearcher(builder.Search)
+}
+func NewSearcherWithSoftDelete(db *dynamodb.DynamoDB, modelType reflect.Type, buildQuery func(interface{}) (dynamodb.QueryInput, error), softDelete *SoftDelete, options ...func(context.Context, interface{}) (interface{}, error)) *Searcher {
+	builder := NewQuerySearchBuilder(db, modelType, buildQuery, options...)
+	builder.SoftDelete = softDelete
+	return NewSearcher(builder.Search)
+}
+func NewSearcherWithSort(db *dynamodb.DynamoDB, modelType reflect.Type, buildQuery func(interface{}) (dynamodb.QueryInput, error), buildSort func(interface{}) ([]Sort, error), options ...func(context.Context, interface{}) (interface{}, error)) *Searcher {
+	builder := NewQuerySearchBuilder(db, modelType, buildQuery, options...)
+	builder.BuildSort = buildSort
+	return NewSearcher(builder.Search)
+}
+
+// QueryBuilder builds the QueryInput of a search model, the sort done client side and the exclusive bounds dropped client side.
+type QueryBuilder interface {
+	BuildQueryInput(m interface{}) (dynamodb.QueryInput, error)
+	BuildSort(m interface{}) ([]Sort, error)
+	BuildBoundaries(m interface{}) ([]Boundary, error)
+}
+
+func NewSearcherWithBuilder(db *dynamodb.DynamoDB, modelType reflect.Type, queryBuilder QueryBuilder, options ...func(context.Context, interface{}) (interface{}, error)) *Searcher {
+	builder := NewQuerySearchBuilder(db, modelType, queryBuilder.BuildQueryInput, options...)
+	builder.BuildSort = queryBuilder.BuildSort
+	builder.BuildBoundaries = queryBuilder.BuildBoundaries
 	return NewSearcher(builder.Search)
 }
 func NewSearcher(search func(context.Context, interface{}, interface{}, int64, ...int64) (int64, string, error)) *Searcher {
@@ -0,0 +1,280 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"reflect"
+	"time"
+)
+
+const (
+	RefreshTokenActive  = "active"
+	RefreshTokenRotated = "rotated"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a rotated refresh token is used again, the session of the token family is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+type Session struct {
+	Id             string            `json:"id" dynamodbav:"id"`
+	UserId         string            `json:"userId" dynamodbav:"userId"`
+	Data           map[string]string `json:"data,omitempty" dynamodbav:"data,omitempty"`
+	CreatedAt      time.Time         `json:"createdAt" dynamodbav:"createdAt"`
+	LastAccessedAt time.Time         `json:"lastAccessedAt" dynamodbav:"lastAccessedAt"`
+	ExpiredAt      TTL               `json:"expiredAt" dynamodbav:"expiredAt"`
+	Revoked        bool              `json:"revoked,omitempty" dynamodbav:"revoked,omitempty"`
+}
+
+// refreshToken is stored in the refresh token table with the hash of the token as id; its family is the session.
+type refreshToken struct {
+	Id        string `dynamodbav:"id"`
+	SessionId string `dynamodbav:"sessionId"`
+	Status    string `dynamodbav:"status"`
+	ExpiredAt TTL    `dynamodbav:"expiredAt"`
+}
+
+// SessionRepository stores the sessions with a sliding Expiry, a revoked session is soft deleted.
+// The table has the key id and a GSI on userId. The refresh tokens are in their own table with the key id,
+// so that the reads of the sessions, such as All and the searches, only return sessions.
+type SessionRepository struct {
+	*Writer
+	refreshTableName string
+	indexName        string
+	Expiry           time.Duration
+	RefreshExpiry    time.Duration
+	Now              func() time.Time
+}
+
+func NewSessionRepository(db *dynamodb.DynamoDB, tableName string, refreshTableName string, indexName string, expiry time.Duration, refreshExpiry time.Duration) *SessionRepository {
+	writer := NewWriter(db, tableName, reflect.TypeOf(Session{}), "Id", "")
+	writer.SoftDelete = NewSoftDeleteFlag("revoked", "", 0)
+	return &SessionRepository{Writer: writer, refreshTableName: refreshTableName, indexName: indexName, Expiry: expiry, RefreshExpiry: refreshExpiry}
+}
+
+func (r *SessionRepository) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return "refresh#" + hex.EncodeToString(h[:])
+}
+
+func (r *SessionRepository) newRefreshToken(sessionId string, now time.Time) (string, *dynamodb.TransactWriteItem, error) {
+	token, err := randomId()
+	if err != nil {
+		return "", nil, err
+	}
+	item, err := dynamodbattribute.MarshalMap(refreshToken{Id: hashToken(token), SessionId: sessionId, Status: RefreshTokenActive, ExpiredAt: TTL(now.Add(r.RefreshExpiry))})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+		TableName:           aws.String(r.refreshTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}}, nil
+}
+
+// Create creates a session of the user with its first refresh token.
+func (r *SessionRepository) Create(ctx context.Context, userId string, data map[string]string) (*Session, string, error) {
+	id, err := randomId()
+	if err != nil {
+		return nil, "", err
+	}
+	now := r.now()
+	session := &Session{Id: id, UserId: userId, Data: data, CreatedAt: now, LastAccessedAt: now, ExpiredAt: TTL(now.Add(r.Expiry))}
+	item, err := dynamodbattribute.MarshalMap(session)
+	if err != nil {
+		return nil, "", err
+	}
+	token, put, err := r.newRefreshToken(id, now)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = r.Database.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []*dynamodb.TransactWriteItem{
+		{Put: &dynamodb.Put{TableName: aws.String(r.tableName), Item: item, ConditionExpression: aws.String("attribute_not_exists(id)")}},
+		put,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// Get returns the session, or nil if it does not exist, is expired or revoked.
+func (r *SessionRepository) Get(ctx context.Context, id string) (*Session, error) {
+	item, err := GetItem(ctx, r.Database, r.tableName, r.Keys(), id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.exists(item, false) {
+		return nil, nil
+	}
+	var session Session
+	if err = dynamodbattribute.UnmarshalMap(item, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// active is true if the session is neither revoked nor expired.
+func (r *SessionRepository) active(now time.Time) expression.ConditionBuilder {
+	return r.SoftDelete.NotDeleted().And(expression.Name("expiredAt").GreaterThan(expression.Value(now.Unix())))
+}
+
+func (r *SessionRepository) extend(now time.Time) expression.UpdateBuilder {
+	return expression.Set(expression.Name("lastAccessedAt"), expression.Value(now)).
+		Set(expression.Name("expiredAt"), expression.Value(TTL(now.Add(r.Expiry))))
+}
+
+// Touch extends the expiry of an active session, it returns ErrSessionNotFound otherwise.
+func (r *SessionRepository) Touch(ctx context.Context, id string) (int64, error) {
+	now := r.now()
+	active := r.active(now)
+	res, err := UpdateWithExpression(ctx, r.Database, r.tableName, r.Keys(), id, r.extend(now), WriteOptions{Condition: &active})
+	if IsConditionalCheckFailed(err) {
+		return res, ErrSessionNotFound
+	}
+	return res, err
+}
+
+// Revoke revokes the session and so all refresh tokens of its family.
+func (r *SessionRepository) Revoke(ctx context.Context, id string) (int64, error) {
+	res, err := r.Delete(ctx, id)
+	if IsConditionalCheckFailed(err) {
+		return res, ErrSessionNotFound
+	}
+	return res, err
+}
+
+// RevokeAll revokes all sessions of the user and returns the number of revoked sessions.
+func (r *SessionRepository) RevokeAll(ctx context.Context, userId string) (int64, error) {
+	keyCondition := expression.Key("userId").Equal(expression.Value(userId))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).WithProjection(expression.NamesList(expression.Name("id"))).Build()
+	if err != nil {
+		return 0, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(r.indexName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	var ids []string
+	err = r.Database.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if v, ok := item["id"]; ok && v.S != nil {
+				ids = append(ids, *v.S)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	for _, id := range ids {
+		_, er2 := r.Delete(ctx, id)
+		if er2 == nil {
+			count++
+		} else if !IsConditionalCheckFailed(er2) {
+			return count, er2
+		}
+	}
+	return count, nil
+}
+
+// Rotate replaces the refresh token by a new one and extends the session.
+// Using a rotated token again revokes the session and returns ErrRefreshTokenReused.
+func (r *SessionRepository) Rotate(ctx context.Context, token string) (*Session, string, error) {
+	now := r.now()
+	tokenKey := map[string]*dynamodb.AttributeValue{"id": {S: aws.String(hashToken(token))}}
+	output, err := r.Database.GetItemWithContext(ctx, &dynamodb.GetItemInput{TableName: aws.String(r.refreshTableName), Key: tokenKey, ConsistentRead: aws.Bool(true)})
+	if err != nil {
+		return nil, "", err
+	}
+	var old refreshToken
+	if len(output.Item) == 0 || IsExpired(output.Item, "expiredAt", now) {
+		return nil, "", ErrRefreshTokenInvalid
+	}
+	if err = dynamodbattribute.UnmarshalMap(output.Item, &old); err != nil {
+		return nil, "", err
+	}
+	if old.Status != RefreshTokenActive {
+		return nil, "", r.reused(ctx, old.SessionId)
+	}
+	newToken, put, err := r.newRefreshToken(old.SessionId, now)
+	if err != nil {
+		return nil, "", err
+	}
+	sessionKey, err := buildKeyMap(r.Keys(), old.SessionId)
+	if err != nil {
+		return nil, "", err
+	}
+	sessionExpr, err := expression.NewBuilder().WithUpdate(r.extend(now)).WithCondition(r.active(now)).Build()
+	if err != nil {
+		return nil, "", err
+	}
+	tokenExpr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("status"), expression.Value(RefreshTokenRotated))).
+		WithCondition(expression.Name("status").Equal(expression.Value(RefreshTokenActive))).Build()
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = r.Database.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []*dynamodb.TransactWriteItem{
+		{Update: &dynamodb.Update{
+			TableName:                 aws.String(r.tableName),
+			Key:                       sessionKey,
+			UpdateExpression:          sessionExpr.Update(),
+			ConditionExpression:       sessionExpr.Condition(),
+			ExpressionAttributeNames:  sessionExpr.Names(),
+			ExpressionAttributeValues: sessionExpr.Values(),
+		}},
+		{Update: &dynamodb.Update{
+			TableName:                 aws.String(r.refreshTableName),
+			Key:                       tokenKey,
+			UpdateExpression:          tokenExpr.Update(),
+			ConditionExpression:       tokenExpr.Condition(),
+			ExpressionAttributeNames:  tokenExpr.Names(),
+			ExpressionAttributeValues: tokenExpr.Values(),
+		}},
+		put,
+	}})
+	if err != nil {
+		if e, ok := err.(*dynamodb.TransactionCanceledException); ok && len(e.CancellationReasons) >= 2 {
+			if aws.StringValue(e.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+				return nil, "", ErrSessionNotFound
+			}
+			if aws.StringValue(e.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+				// The token has been rotated concurrently.
+				return nil, "", r.reused(ctx, old.SessionId)
+			}
+		}
+		return nil, "", err
+	}
+	session, err := r.Get(ctx, old.SessionId)
+	return session, newToken, err
+}
+
+func (r *SessionRepository) reused(ctx context.Context, sessionId string) error {
+	if _, err := r.Delete(ctx, sessionId); err != nil && !IsConditionalCheckFailed(err) {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
@@ -0,0 +1,155 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"testing"
+	"time"
+)
+
+func newTestSessionRepository(db *dynamodb.DynamoDB) *SessionRepository {
+	r := NewSessionRepository(db, "sessions", "refresh_tokens", "user-index", time.Hour, 24*time.Hour)
+	r.Now = func() time.Time {
+		return time.Unix(1700000000, 0)
+	}
+	return r
+}
+
+// storedSession expires after the real time, which the loader uses to skip the expired items.
+func storedSession(id string) map[string]*dynamodb.AttributeValue {
+	item, _ := dynamodbattribute.MarshalMap(Session{Id: id, UserId: "u1", ExpiredAt: TTL(time.Now().Add(time.Hour))})
+	return item
+}
+
+func storedRefreshToken(token string, status string, expiredAt int64) map[string]*dynamodb.AttributeValue {
+	item, _ := dynamodbattribute.MarshalMap(refreshToken{Id: hashToken(token), SessionId: "s1", Status: status, ExpiredAt: TTL(time.Unix(expiredAt, 0))})
+	return item
+}
+
+func TestSessionCreate(t *testing.T) {
+	db, fake := newFakeDynamoDB(t, nil)
+	session, token, err := newTestSessionRepository(db).Create(context.Background(), "u1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input dynamodb.TransactWriteItemsInput
+	fake.Requests("TransactWriteItems")[0].Decode(t, &input)
+	if len(input.TransactItems) != 2 {
+		t.Fatalf("%d items, want the session and its refresh token", len(input.TransactItems))
+	}
+	if put := input.TransactItems[0].Put; aws.StringValue(put.TableName) != "sessions" || aws.StringValue(put.Item["id"].S) != session.Id {
+		t.Errorf("session put = %v", put)
+	}
+	put := input.TransactItems[1].Put
+	if aws.StringValue(put.TableName) != "refresh_tokens" || aws.StringValue(put.Item["id"].S) != hashToken(token) || aws.StringValue(put.Item["sessionId"].S) != session.Id {
+		t.Errorf("refresh token put = %v, want the hash of the token in the refresh token table", put)
+	}
+	if v := put.Item["expiredAt"]; v == nil || aws.StringValue(v.N) != "1700086400" {
+		t.Errorf("expiredAt = %v, want now + refresh expiry", v)
+	}
+}
+
+func TestSessionReadsExcludeRefreshTokens(t *testing.T) {
+	db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+		if r.Operation == "Scan" {
+			return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{storedSession("s1")}}
+		}
+		return nil
+	})
+	r := newTestSessionRepository(db)
+	if _, _, err := r.Create(context.Background(), "u1", nil); err != nil {
+		t.Fatal(err)
+	}
+	all, err := r.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions, ok := all.(*[]Session); !ok || len(*sessions) != 1 {
+		t.Errorf("All = %#v, want the session only", all)
+	}
+	var input dynamodb.ScanInput
+	fake.Requests("Scan")[0].Decode(t, &input)
+	if aws.StringValue(input.TableName) != "sessions" {
+		t.Errorf("scan of %s, want the session table", aws.StringValue(input.TableName))
+	}
+}
+
+func TestSessionRotate(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   map[string]*dynamodb.AttributeValue
+		reasons []*dynamodb.CancellationReason
+		err     error
+		writes  []string
+	}{
+		{"active token", storedRefreshToken("t1", RefreshTokenActive, 1700086400), nil, nil, []string{"TransactWriteItems"}},
+		{"unknown token", nil, nil, ErrRefreshTokenInvalid, nil},
+		{"expired token", storedRefreshToken("t1", RefreshTokenActive, 1699999999), nil, ErrRefreshTokenInvalid, nil},
+		{"rotated token", storedRefreshToken("t1", RefreshTokenRotated, 1700086400), nil, ErrRefreshTokenReused, []string{"UpdateItem"}},
+		{"revoked session", storedRefreshToken("t1", RefreshTokenActive, 1700086400), []*dynamodb.CancellationReason{
+			{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}, {Code: aws.String("None")},
+		}, ErrSessionNotFound, []string{"TransactWriteItems"}},
+		{"concurrent rotation", storedRefreshToken("t1", RefreshTokenActive, 1700086400), []*dynamodb.CancellationReason{
+			{Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")},
+		}, ErrRefreshTokenReused, []string{"TransactWriteItems", "UpdateItem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				switch r.Operation {
+				case "GetItem":
+					var input dynamodb.GetItemInput
+					r.Decode(t, &input)
+					if aws.StringValue(input.TableName) == "refresh_tokens" {
+						return &dynamodb.GetItemOutput{Item: tt.token}
+					}
+					return &dynamodb.GetItemOutput{Item: storedSession("s1")}
+				case "TransactWriteItems":
+					if tt.reasons != nil {
+						return fakeError{Code: dynamodb.ErrCodeTransactionCanceledException, Reasons: tt.reasons}
+					}
+				}
+				return nil
+			})
+			session, token, err := newTestSessionRepository(db).Rotate(context.Background(), "t1")
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			var writes []string
+			for _, r := range fake.Requests("") {
+				if r.Operation != "GetItem" {
+					writes = append(writes, r.Operation)
+				}
+			}
+			if len(writes) != len(tt.writes) {
+				t.Fatalf("writes = %v, want %v", writes, tt.writes)
+			}
+			for i := range writes {
+				if writes[i] != tt.writes[i] {
+					t.Errorf("writes = %v, want %v", writes, tt.writes)
+				}
+			}
+			if tt.err != nil {
+				return
+			}
+			if session == nil || session.Id != "s1" || len(token) == 0 || token == "t1" {
+				t.Errorf("Rotate = %+v, %q, want the session and a new token", session, token)
+			}
+			var input dynamodb.TransactWriteItemsInput
+			fake.Requests("TransactWriteItems")[0].Decode(t, &input)
+			tables := []string{
+				aws.StringValue(input.TransactItems[0].Update.TableName),
+				aws.StringValue(input.TransactItems[1].Update.TableName),
+				aws.StringValue(input.TransactItems[2].Put.TableName),
+			}
+			if tables[0] != "sessions" || tables[1] != "refresh_tokens" || tables[2] != "refresh_tokens" {
+				t.Errorf("tables = %v, want the session and the refresh tokens in their tables", tables)
+			}
+			if id := aws.StringValue(input.TransactItems[1].Update.Key["id"].S); id != hashToken("t1") {
+				t.Errorf("rotated token = %s, want the hash of the old token", id)
+			}
+		})
+	}
+}
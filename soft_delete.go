@@ -0,0 +1,132 @@
+package dynamodb
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"strconv"
+	"time"
+)
+
+type SoftDelete struct {
+	// Name is the attribute marking a deleted item: true if Flag, the deletion time otherwise.
+	Name string
+	Flag bool
+	// TTLName is the TTL attribute of the table, it receives the time to purge the item in epoch seconds.
+	TTLName string
+	TTL     time.Duration
+	Now     func() time.Time
+}
+
+// NewSoftDelete marks a deleted item with the deletion time in name, and optionally sets ttlName to purge the item after ttl.
+func NewSoftDelete(name string, ttlName string, ttl time.Duration) *SoftDelete {
+	return &SoftDelete{Name: name, TTLName: ttlName, TTL: ttl}
+}
+
+// NewSoftDeleteFlag marks a deleted item with name = true.
+func NewSoftDeleteFlag(name string, ttlName string, ttl time.Duration) *SoftDelete {
+	return &SoftDelete{Name: name, Flag: true, TTLName: ttlName, TTL: ttl}
+}
+
+func (s *SoftDelete) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// NotDeleted is true for items without the deletion mark, a null mark (or false flag) is not deleted.
+func (s *SoftDelete) NotDeleted() expression.ConditionBuilder {
+	name := expression.Name(s.Name)
+	if s.Flag {
+		return expression.AttributeNotExists(name).Or(name.Equal(expression.Value(false)))
+	}
+	return expression.AttributeNotExists(name).Or(expression.AttributeType(name, expression.Null))
+}
+
+func (s *SoftDelete) IsDeleted(item map[string]*dynamodb.AttributeValue) bool {
+	v, ok := item[s.Name]
+	if !ok || v == nil || aws.BoolValue(v.NULL) {
+		return false
+	}
+	if s.Flag {
+		return aws.BoolValue(v.BOOL)
+	}
+	return true
+}
+
+func (s *SoftDelete) markDeleted() expression.UpdateBuilder {
+	now := s.now()
+	var update expression.UpdateBuilder
+	if s.Flag {
+		update = update.Set(expression.Name(s.Name), expression.Value(true))
+	} else {
+		update = update.Set(expression.Name(s.Name), expression.Value(now.Format(time.RFC3339Nano)))
+	}
+	if len(s.TTLName) > 0 && s.TTL > 0 {
+		expiry := &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(s.TTL).Unix(), 10))}
+		update = update.Set(expression.Name(s.TTLName), expression.Value(expiry))
+	}
+	return update
+}
+
+func (s *SoftDelete) deleteFailed(item map[string]*dynamodb.AttributeValue) string {
+	if len(item) == 0 || s.IsDeleted(item) {
+		return ObjectNotFound
+	}
+	return ConditionCheckFailed
+}
+
+// ExcludeDeleted adds the not deleted condition to the filter of the query.
+func ExcludeDeleted(query *dynamodb.ScanInput, softDelete *SoftDelete) {
+	if softDelete == nil {
+		return
+	}
+	filter, names, values := softDelete.filter()
+	AddFilter(query, filter, names, values)
+}
+
+func ExcludeDeletedFromQuery(query *dynamodb.QueryInput, softDelete *SoftDelete) {
+	if softDelete == nil {
+		return
+	}
+	filter, names, values := softDelete.filter()
+	AddQueryFilter(query, filter, names, values)
+}
+
+func (s *SoftDelete) filter() (string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	names := map[string]*string{"#softDeleted": aws.String(s.Name)}
+	if s.Flag {
+		values := map[string]*dynamodb.AttributeValue{":softDeleted": {BOOL: aws.Bool(false)}}
+		return "(attribute_not_exists(#softDeleted) OR #softDeleted = :softDeleted)", names, values
+	}
+	values := map[string]*dynamodb.AttributeValue{":softDeleted": {S: aws.String("NULL")}}
+	return "(attribute_not_exists(#softDeleted) OR attribute_type(#softDeleted, :softDeleted))", names, values
+}
+
+// SoftDeleteOne marks the item as deleted, it fails as not found if the item does not exist or is already deleted.
+func SoftDeleteOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, softDelete *SoftDelete, options ...WriteOptions) (int64, error) {
+	keyMap, err := buildKeyMap(keys, id)
+	if err != nil {
+		return 0, err
+	}
+	opts := getWriteOptions(options)
+	notDeleted := softDelete.NotDeleted()
+	return updateItem(ctx, db, tableName, keyMap, softDelete.markDeleted(), And(keyExists(keys), &notDeleted, opts.Condition), softDelete.deleteFailed, opts)
+}
+
+// RestoreOne removes the deletion mark and the TTL of a soft deleted item.
+func RestoreOne(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []string, id interface{}, softDelete *SoftDelete, options ...WriteOptions) (int64, error) {
+	keyMap, err := buildKeyMap(keys, id)
+	if err != nil {
+		return 0, err
+	}
+	opts := getWriteOptions(options)
+	update := expression.Remove(expression.Name(softDelete.Name))
+	if len(softDelete.TTLName) > 0 {
+		update = update.Remove(expression.Name(softDelete.TTLName))
+	}
+	deleted := expression.Not(softDelete.NotDeleted())
+	return updateItem(ctx, db, tableName, keyMap, update, And(keyExists(keys), &deleted, opts.Condition), updateFailed, opts)
+}
@@ -0,0 +1,78 @@
+package dynamodb
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"testing"
+)
+
+func buildCondition(t *testing.T, c *expression.ConditionBuilder) (string, map[string]*string) {
+	t.Helper()
+	if c == nil {
+		return "", nil
+	}
+	expr, err := expression.NewBuilder().WithCondition(*c).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aws.StringValue(expr.Condition()), expr.Names()
+}
+
+func TestWriteConditionsWithSoftDelete(t *testing.T) {
+	softDelete := NewSoftDelete("deletedAt", "", 0)
+	flag := NewSoftDeleteFlag("deleted", "", 0)
+	tests := []struct {
+		name      string
+		condition *expression.ConditionBuilder
+		want      string
+		names     []string
+	}{
+		{"exists", existsCondition([]string{"id"}, WriteOptions{}), "attribute_exists (#0)", []string{"id"}},
+		{"exists not deleted", existsCondition([]string{"id"}, WriteOptions{SoftDelete: softDelete}), "(attribute_exists (#0)) AND ((attribute_not_exists (#1)) OR (attribute_type (#1, :0)))", []string{"id", "deletedAt"}},
+		{"exists not flagged", existsCondition([]string{"id"}, WriteOptions{SoftDelete: flag}), "(attribute_exists (#0)) AND ((attribute_not_exists (#1)) OR (#1 = :0))", []string{"id", "deleted"}},
+		{"not exists", notExistsCondition([]string{"id"}, WriteOptions{}), "attribute_not_exists (#0)", []string{"id"}},
+		{"not exists or deleted", notExistsCondition([]string{"id"}, WriteOptions{SoftDelete: softDelete}), "(attribute_not_exists (#0)) OR (NOT ((attribute_not_exists (#1)) OR (attribute_type (#1, :0))))", []string{"id", "deletedAt"}},
+		{"no keys", notExistsCondition(nil, WriteOptions{SoftDelete: softDelete}), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, names := buildCondition(t, tt.condition)
+			if got != tt.want {
+				t.Errorf("condition = %q, want %q", got, tt.want)
+			}
+			for i, name := range tt.names {
+				placeholder := fmt.Sprintf("#%d", i)
+				if aws.StringValue(names[placeholder]) != name {
+					t.Errorf("name %s = %q, want %q", placeholder, aws.StringValue(names[placeholder]), name)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteErrorOfSoftDeletedItem(t *testing.T) {
+	softDelete := NewSoftDelete("deletedAt", "", 0)
+	tombstone := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}, "deletedAt": {S: aws.String("2026-01-01T00:00:00Z")}}
+	restored := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}, "deletedAt": {NULL: aws.Bool(true)}}
+	tests := []struct {
+		name    string
+		item    map[string]*dynamodb.AttributeValue
+		options WriteOptions
+		want    string
+	}{
+		{"missing", nil, WriteOptions{SoftDelete: softDelete}, ObjectNotFound},
+		{"deleted", tombstone, WriteOptions{SoftDelete: softDelete}, ObjectNotFound},
+		{"deleted without soft delete", tombstone, WriteOptions{}, ConditionCheckFailed},
+		{"not deleted", restored, WriteOptions{SoftDelete: softDelete}, ConditionCheckFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := toWriteError(&dynamodb.ConditionalCheckFailedException{Item: tt.item}, tt.options, updateFailed)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrTooManyItemsToSort = errors.New("too many items to sort, add conditions to the search or sort by the sort key of an index")
+
+// Sort is the order of the search results by an attribute, when they are sorted client side.
+type Sort struct {
+	Name string
+	Desc bool
+}
+
+// Boundary is an exclusive bound of a range on a sort key. The key condition of the range includes it, because BETWEEN is inclusive
+// and the filter of a Query cannot use a key, so the items whose attribute is the bound are dropped client side.
+type Boundary struct {
+	Name  string
+	Value *dynamodb.AttributeValue
+}
+
+// ExcludeBoundaries returns the items which are not on a boundary.
+func ExcludeBoundaries(items []map[string]*dynamodb.AttributeValue, boundaries []Boundary) []map[string]*dynamodb.AttributeValue {
+	if len(boundaries) == 0 {
+		return items
+	}
+	var kept []map[string]*dynamodb.AttributeValue
+	for _, item := range items {
+		if !onBoundary(item, boundaries) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func onBoundary(item map[string]*dynamodb.AttributeValue, boundaries []Boundary) bool {
+	for _, b := range boundaries {
+		v := item[b.Name]
+		if v == nil || b.Value == nil {
+			continue
+		}
+		if v.N != nil && b.Value.N != nil && compareAttributes(v, b.Value) == 0 {
+			return true
+		}
+		if v.S != nil && b.Value.S != nil && *v.S == *b.Value.S {
+			return true
+		}
+		if v.B != nil && b.Value.B != nil && bytes.Equal(v.B, b.Value.B) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadItems reads the items of a Query, or of a Scan if there is no key condition, and fails with ErrTooManyItemsToSort if there are more than max items.
+// The items on the boundaries are dropped.
+func ReadItems(ctx context.Context, db *dynamodb.DynamoDB, query dynamodb.QueryInput, max int64, boundaries ...Boundary) ([]map[string]*dynamodb.AttributeValue, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	exceeded := false
+	collect := func(page []map[string]*dynamodb.AttributeValue) bool {
+		items = append(items, ExcludeBoundaries(page, boundaries)...)
+		if max > 0 && int64(len(items)) > max {
+			exceeded = true
+			return false
+		}
+		return true
+	}
+	var err error
+	if query.KeyConditionExpression == nil {
+		scan := ToScanInput(&query)
+		err = db.ScanPagesWithContext(ctx, &scan, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+			return collect(page.Items)
+		})
+	} else {
+		err = db.QueryPagesWithContext(ctx, &query, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+			return collect(page.Items)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if exceeded {
+		return nil, ErrTooManyItemsToSort
+	}
+	return items, nil
+}
+
+// SortItems sorts the items by the attributes; a missing attribute is before the other values.
+func SortItems(items []map[string]*dynamodb.AttributeValue, sorts []Sort) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, s := range sorts {
+			c := compareAttributes(items[i][s.Name], items[j][s.Name])
+			if c != 0 {
+				return (c < 0) != s.Desc
+			}
+		}
+		return false
+	})
+}
+
+func compareAttributes(a *dynamodb.AttributeValue, b *dynamodb.AttributeValue) int {
+	if a == nil || aws.BoolValue(a.NULL) {
+		if b == nil || aws.BoolValue(b.NULL) {
+			return 0
+		}
+		return -1
+	}
+	if b == nil || aws.BoolValue(b.NULL) {
+		return 1
+	}
+	if a.N != nil && b.N != nil {
+		x, _ := strconv.ParseFloat(*a.N, 64)
+		y, _ := strconv.ParseFloat(*b.N, 64)
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+		return 0
+	}
+	if a.BOOL != nil && b.BOOL != nil {
+		if *a.BOOL == *b.BOOL {
+			return 0
+		} else if *b.BOOL {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(aws.StringValue(a.S), aws.StringValue(b.S))
+}
+
+// BuildSortedResult reads at most max items of the query, sorts them and decodes the page of limit items at pageIndex into results.
+// It returns the number of matching items.
+func BuildSortedResult(ctx context.Context, db *dynamodb.DynamoDB, results interface{}, query dynamodb.QueryInput, sorts []Sort, max int64, limit int64, pageIndex int64, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error) {
+	return buildSortedResult(ctx, db, results, query, nil, sorts, max, limit, pageIndex, options...)
+}
+
+func buildSortedResult(ctx context.Context, db *dynamodb.DynamoDB, results interface{}, query dynamodb.QueryInput, boundaries []Boundary, sorts []Sort, max int64, limit int64, pageIndex int64, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error) {
+	items, err := ReadItems(ctx, db, query, max, boundaries...)
+	if err != nil {
+		return 0, err
+	}
+	SortItems(items, sorts)
+	total := int64(len(items))
+	if limit > 0 {
+		if pageIndex < 1 {
+			pageIndex = 1
+		}
+		start := (pageIndex - 1) * limit
+		if start > total {
+			start = total
+		}
+		end := start + limit
+		if end > total {
+			end = total
+		}
+		items = items[start:end]
+	}
+	if err = dynamodbattribute.UnmarshalListOfMaps(items, results); err != nil {
+		return total, err
+	}
+	if len(options) > 0 && options[0] != nil {
+		_, err = MapModels(ctx, results, options[0])
+	}
+	return total, err
+}
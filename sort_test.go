@@ -0,0 +1,83 @@
+package dynamodb
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"reflect"
+	"testing"
+)
+
+func TestSortItems(t *testing.T) {
+	item := func(id string, name *string, age *string) map[string]*dynamodb.AttributeValue {
+		m := map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}}
+		if name != nil {
+			m["name"] = &dynamodb.AttributeValue{S: name}
+		}
+		if age != nil {
+			m["age"] = &dynamodb.AttributeValue{N: age}
+		}
+		return m
+	}
+	items := []map[string]*dynamodb.AttributeValue{
+		item("1", aws.String("bob"), aws.String("30")),
+		item("2", aws.String("alice"), aws.String("9")),
+		item("3", nil, aws.String("30")),
+		item("4", aws.String("bob"), aws.String("100")),
+		item("5", aws.String("alice"), nil),
+	}
+	tests := []struct {
+		name  string
+		sorts []Sort
+		want  []string
+	}{
+		{"no sort keeps the order", nil, []string{"1", "2", "3", "4", "5"}},
+		{"string, missing first", []Sort{{Name: "name"}}, []string{"3", "2", "5", "1", "4"}},
+		{"number, not as a string", []Sort{{Name: "age"}}, []string{"5", "2", "1", "3", "4"}},
+		{"descending", []Sort{{Name: "age", Desc: true}}, []string{"4", "1", "3", "2", "5"}},
+		{"two attributes", []Sort{{Name: "name"}, {Name: "age", Desc: true}}, []string{"3", "2", "5", "4", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := append([]map[string]*dynamodb.AttributeValue{}, items...)
+			SortItems(sorted, tt.sorts)
+			var ids []string
+			for _, item := range sorted {
+				ids = append(ids, aws.StringValue(item["id"].S))
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("sorted = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeBoundaries(t *testing.T) {
+	items := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "amount": {N: aws.String("10")}},
+		{"id": {S: aws.String("2")}, "amount": {N: aws.String("15")}},
+		{"id": {S: aws.String("3")}, "amount": {N: aws.String("20.0")}},
+		{"id": {S: aws.String("4")}},
+	}
+	tests := []struct {
+		name       string
+		boundaries []Boundary
+		items      []string
+	}{
+		{"no boundary", nil, []string{"1", "2", "3", "4"}},
+		{"lower bound", []Boundary{{Name: "amount", Value: &dynamodb.AttributeValue{N: aws.String("10")}}}, []string{"2", "3", "4"}},
+		{"equal numbers", []Boundary{{Name: "amount", Value: &dynamodb.AttributeValue{N: aws.String("20")}}}, []string{"1", "2", "4"}},
+		{"string bound of a number", []Boundary{{Name: "amount", Value: &dynamodb.AttributeValue{S: aws.String("10")}}}, []string{"1", "2", "3", "4"}},
+		{"string bound", []Boundary{{Name: "id", Value: &dynamodb.AttributeValue{S: aws.String("4")}}}, []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kept []string
+			for _, item := range ExcludeBoundaries(items, tt.boundaries) {
+				kept = append(kept, aws.StringValue(item["id"].S))
+			}
+			if !reflect.DeepEqual(kept, tt.items) {
+				t.Errorf("items = %v, want %v", kept, tt.items)
+			}
+		})
+	}
+}
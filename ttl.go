@@ -0,0 +1,165 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TTL is a time stored as a Number in epoch seconds, the format required by the time to live of DynamoDB.
+// A time.Time field tagged `dynamodbav:"expiredAt,unixtime"` is stored the same way.
+type TTL time.Time
+
+func NewTTL(d time.Duration) TTL {
+	return TTL(time.Now().Add(d))
+}
+
+func (t TTL) Time() time.Time {
+	return time.Time(t)
+}
+
+func (t TTL) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	if time.Time(t).IsZero() {
+		av.NULL = aws.Bool(true)
+		return nil
+	}
+	av.N = aws.String(strconv.FormatInt(time.Time(t).Unix(), 10))
+	return nil
+}
+
+// UnmarshalDynamoDBAttributeValue reads epoch seconds, and RFC3339 strings written before the attribute was a TTL.
+func (t *TTL) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	if av.N != nil {
+		sec, err := strconv.ParseFloat(*av.N, 64)
+		if err != nil {
+			return err
+		}
+		*t = TTL(time.Unix(int64(sec), 0))
+		return nil
+	}
+	if av.S != nil {
+		v, err := time.Parse(time.RFC3339Nano, *av.S)
+		if err != nil {
+			return err
+		}
+		*t = TTL(v)
+		return nil
+	}
+	*t = TTL{}
+	return nil
+}
+
+func (t TTL) MarshalJSON() ([]byte, error) {
+	return time.Time(t).MarshalJSON()
+}
+
+func (t *TTL) UnmarshalJSON(data []byte) error {
+	return (*time.Time)(t).UnmarshalJSON(data)
+}
+
+var ttlType = reflect.TypeOf(TTL{})
+var timeType = reflect.TypeOf(time.Time{})
+
+// GetTTLField returns the index and the attribute name of the field of type TTL, or of the field tagged `ttl:"true"`, or -1 if there is none.
+// A tagged field must be an integer or a time.Time tagged `dynamodbav:",unixtime"`, because DynamoDB never expires a String attribute.
+func GetTTLField(modelType reflect.Type) (int, string, error) {
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		tag, ok := field.Tag.Lookup("ttl")
+		if fieldType != ttlType && (!ok || strings.TrimSpace(tag) != "true") {
+			continue
+		}
+		_, name, _ := GetFieldByIndex(modelType, i)
+		switch fieldType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return i, name, nil
+		}
+		if fieldType == ttlType || (fieldType == timeType && isUnixTime(field)) {
+			return i, name, nil
+		}
+		return -1, "", fmt.Errorf("ttl field %s must be a TTL, an integer or a time.Time tagged unixtime, not %s", field.Name, field.Type.String())
+	}
+	return -1, "", nil
+}
+
+// IsExpired is true when the TTL attribute of the item has passed, DynamoDB may keep such item up to a few days before deleting it.
+func IsExpired(item map[string]*dynamodb.AttributeValue, ttlName string, now time.Time) bool {
+	if len(ttlName) == 0 {
+		return false
+	}
+	v, ok := item[ttlName]
+	if !ok || v == nil || v.N == nil {
+		return false
+	}
+	sec, err := strconv.ParseFloat(*v.N, 64)
+	if err != nil {
+		return false
+	}
+	return int64(sec) <= now.Unix()
+}
+
+// ExcludeExpired adds the not expired condition to the filter of the query; an item without a Number TTL never expires.
+func ExcludeExpired(query *dynamodb.ScanInput, ttlName string, now time.Time) {
+	if len(ttlName) == 0 {
+		return
+	}
+	names := map[string]*string{"#ttl": aws.String(ttlName)}
+	values := map[string]*dynamodb.AttributeValue{
+		":ttlNow":  {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		":ttlType": {S: aws.String(dynamodb.ScalarAttributeTypeN)},
+	}
+	AddFilter(query, "(attribute_not_exists(#ttl) OR NOT attribute_type(#ttl, :ttlType) OR #ttl > :ttlNow)", names, values)
+}
+
+// EnableTTL enables the time to live of the table on the attribute, it does nothing if it is already enabled on this attribute.
+func EnableTTL(ctx context.Context, db *dynamodb.DynamoDB, tableName string, attributeName string) error {
+	output, err := db.DescribeTimeToLiveWithContext(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return err
+	}
+	if d := output.TimeToLiveDescription; d != nil && aws.StringValue(d.AttributeName) == attributeName {
+		status := aws.StringValue(d.TimeToLiveStatus)
+		if status == dynamodb.TimeToLiveStatusEnabled || status == dynamodb.TimeToLiveStatusEnabling {
+			return nil
+		}
+	}
+	return UpdateTimeToLive(ctx, db, tableName, attributeName, true)
+}
+
+// DisableTTL disables the time to live of the table, it does nothing if it is already disabled.
+func DisableTTL(ctx context.Context, db *dynamodb.DynamoDB, tableName string) error {
+	output, err := db.DescribeTimeToLiveWithContext(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return err
+	}
+	d := output.TimeToLiveDescription
+	if d == nil || d.AttributeName == nil {
+		return nil
+	}
+	status := aws.StringValue(d.TimeToLiveStatus)
+	if status == dynamodb.TimeToLiveStatusDisabled || status == dynamodb.TimeToLiveStatusDisabling {
+		return nil
+	}
+	return UpdateTimeToLive(ctx, db, tableName, *d.AttributeName, false)
+}
+
+func UpdateTimeToLive(ctx context.Context, db *dynamodb.DynamoDB, tableName string, attributeName string, enabled bool) error {
+	_, err := db.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(attributeName),
+			Enabled:       aws.Bool(enabled),
+		},
+	})
+	return err
+}
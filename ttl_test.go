@@ -0,0 +1,119 @@
+package dynamodb
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetTTLField(t *testing.T) {
+	tests := []struct {
+		name      string
+		modelType reflect.Type
+		index     int
+		attribute string
+		err       bool
+	}{
+		{"TTL", reflect.TypeOf(struct {
+			Id        string `dynamodbav:"id"`
+			ExpiredAt TTL    `dynamodbav:"expiredAt"`
+		}{}), 1, "expiredAt", false},
+		{"pointer to TTL", reflect.TypeOf(struct {
+			ExpiredAt *TTL `dynamodbav:"expiredAt"`
+		}{}), 0, "expiredAt", false},
+		{"tagged integer", reflect.TypeOf(struct {
+			ExpiredAt int64 `dynamodbav:"expiredAt" ttl:"true"`
+		}{}), 0, "expiredAt", false},
+		{"tagged unix time", reflect.TypeOf(struct {
+			ExpiredAt time.Time `dynamodbav:"expiredAt,unixtime" ttl:"true"`
+		}{}), 0, "expiredAt", false},
+		{"tagged time stored as a string", reflect.TypeOf(struct {
+			ExpiredAt time.Time `dynamodbav:"expiredAt" ttl:"true"`
+		}{}), -1, "", true},
+		{"tagged string", reflect.TypeOf(struct {
+			ExpiredAt string `dynamodbav:"expiredAt" ttl:"true"`
+		}{}), -1, "", true},
+		{"untagged time", reflect.TypeOf(struct {
+			ExpiredAt time.Time `dynamodbav:"expiredAt,unixtime"`
+		}{}), -1, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, attribute, err := GetTTLField(tt.modelType)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if index != tt.index || attribute != tt.attribute {
+				t.Errorf("GetTTLField = %d, %q, want %d, %q", index, attribute, tt.index, tt.attribute)
+			}
+		})
+	}
+}
+
+func TestTTLAttribute(t *testing.T) {
+	expiry := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		av   *dynamodb.AttributeValue
+		ttl  TTL
+	}{
+		{"epoch seconds", &dynamodb.AttributeValue{N: aws.String("1700000000")}, TTL(expiry)},
+		{"RFC3339 string written before the TTL", &dynamodb.AttributeValue{S: aws.String("2023-11-14T22:13:20Z")}, TTL(expiry)},
+		{"null", &dynamodb.AttributeValue{NULL: aws.Bool(true)}, TTL{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ttl TTL
+			if err := dynamodbattribute.Unmarshal(tt.av, &ttl); err != nil {
+				t.Fatal(err)
+			}
+			if !ttl.Time().Equal(tt.ttl.Time()) {
+				t.Errorf("TTL = %v, want %v", ttl.Time(), tt.ttl.Time())
+			}
+		})
+	}
+	av, err := dynamodbattribute.Marshal(TTL(expiry))
+	if err != nil || aws.StringValue(av.N) != "1700000000" {
+		t.Errorf("Marshal = %v, %v, want epoch seconds", av, err)
+	}
+	if av, _ = dynamodbattribute.Marshal(TTL{}); av.NULL == nil {
+		t.Errorf("Marshal of a zero TTL = %v, want NULL", av)
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		ttl     *dynamodb.AttributeValue
+		expired bool
+	}{
+		{"passed", &dynamodb.AttributeValue{N: aws.String("1699999999")}, true},
+		{"now", &dynamodb.AttributeValue{N: aws.String("1700000000")}, true},
+		{"future", &dynamodb.AttributeValue{N: aws.String("1700000001")}, false},
+		{"string", &dynamodb.AttributeValue{S: aws.String("2000-01-01T00:00:00Z")}, false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}}
+			if tt.ttl != nil {
+				item["expiredAt"] = tt.ttl
+			}
+			if expired := IsExpired(item, "expiredAt", now); expired != tt.expired {
+				t.Errorf("IsExpired = %v, want %v", expired, tt.expired)
+			}
+		})
+	}
+	query := &dynamodb.ScanInput{TableName: aws.String("sessions")}
+	ExcludeExpired(query, "expiredAt", now)
+	if filter := aws.StringValue(query.FilterExpression); filter != "(attribute_not_exists(#ttl) OR NOT attribute_type(#ttl, :ttlType) OR #ttl > :ttlNow)" {
+		t.Errorf("filter = %q", filter)
+	}
+	if v := query.ExpressionAttributeValues[":ttlNow"]; v == nil || aws.StringValue(v.N) != "1700000000" {
+		t.Errorf(":ttlNow = %v, want epoch seconds", v)
+	}
+}
@@ -2,8 +2,12 @@ package dynamodb
 
 import (
 	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 type Mapper interface {
@@ -13,9 +17,14 @@ type Mapper interface {
 
 type Writer struct {
 	*Loader
+	Audit        *Audit
+	Outbox       *Outbox
 	maps         map[string]string
+	sets         map[string]string
 	versionField string
 	versionIndex int
+	// normalized are the shadow attributes of the attributes tagged normalize.
+	normalized map[string]string
 }
 
 func NewWriter(db *dynamodb.DynamoDB, tableName string, modelType reflect.Type, partitionKeyName string, sortKeyName string, options ...Mapper) *Writer {
@@ -32,39 +41,266 @@ func NewWriterWithVersion(db *dynamodb.DynamoDB, tableName string, modelType ref
 	}
 	if len(versionFieldName) > 0 {
 		if index, versionField, ok := GetFieldByName(modelType, versionFieldName); ok {
-			return &Writer{Loader: loader, maps: MakeMapObject(modelType), versionField: versionField, versionIndex: index}
+			return &Writer{Loader: loader, Audit: NewAudit(modelType), maps: MakeMapObject(modelType), sets: MakeSetFields(modelType), versionField: versionField, versionIndex: index, normalized: GetNormalizedFields(modelType)}
 		}
 	}
-	return &Writer{Loader: loader, maps: MakeMapObject(modelType), versionField: "", versionIndex: -1}
+	return &Writer{Loader: loader, Audit: NewAudit(modelType), maps: MakeMapObject(modelType), sets: MakeSetFields(modelType), versionField: "", versionIndex: -1, normalized: GetNormalizedFields(modelType)}
 }
 
 func (m *Writer) Insert(ctx context.Context, model interface{}) (int64, error) {
+	return m.InsertWithOptions(ctx, model, WriteOptions{})
+}
+func (m *Writer) InsertWithOptions(ctx context.Context, model interface{}, options WriteOptions) (int64, error) {
+	options = m.withOutbox(options)
+	options = m.withSoftDelete(options)
+	options = withAttributes(options, normalizeModel(m.normalized, model))
+	var res int64
+	var err error
+	if m.Audit != nil {
+		if model, err = m.Audit.OnCreate(ctx, model); err != nil {
+			return 0, err
+		}
+	}
 	if m.versionIndex >= 0 {
-		return InsertOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), model, m.versionIndex, m.versionField)
+		res, err = InsertOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), model, m.versionIndex, m.versionField, options)
+	} else {
+		res, err = InsertOne(ctx, m.Database, m.tableName, m.Keys(), model, options)
 	}
-	return InsertOne(ctx, m.Database, m.tableName, m.Keys(), model)
+	return m.mapResult(ctx, res, err, options)
 }
 
 func (m *Writer) Update(ctx context.Context, model interface{}) (int64, error) {
+	return m.UpdateWithOptions(ctx, model, WriteOptions{})
+}
+func (m *Writer) UpdateWithOptions(ctx context.Context, model interface{}, options WriteOptions) (int64, error) {
+	options = m.withOutbox(options)
+	options = m.withSoftDelete(options)
+	options = withAttributes(options, normalizeModel(m.normalized, model))
+	var res int64
+	var err error
+	if m.Audit != nil {
+		if model, err = m.Audit.OnUpdate(ctx, model); err != nil {
+			return 0, err
+		}
+		if protected := m.Audit.Protected(m.modelType); len(protected) > 0 {
+			res, err = m.updateProtected(ctx, model, protected, options)
+			return m.mapResult(ctx, res, err, options)
+		}
+	}
 	if m.versionIndex >= 0 {
-		return UpdateOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), model, m.versionIndex, m.versionField)
+		res, err = UpdateOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), model, m.versionIndex, m.versionField, options)
+	} else {
+		res, err = UpdateOne(ctx, m.Database, m.tableName, m.Keys(), model, options)
 	}
-	return UpdateOne(ctx, m.Database, m.tableName, m.Keys(), model)
+	return m.mapResult(ctx, res, err, options)
 }
+
 func (m *Writer) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
+	return m.PatchWithOptions(ctx, model, WriteOptions{})
+}
+func (m *Writer) PatchWithOptions(ctx context.Context, model map[string]interface{}, options WriteOptions) (int64, error) {
+	options = m.withOutbox(options)
+	options = m.withSoftDelete(options)
+	dbModel := MapToDBObject(model, m.maps)
+	var err error
+	if m.Audit != nil {
+		if dbModel, err = m.Audit.OnPatch(ctx, m.modelType, dbModel); err != nil {
+			return 0, err
+		}
+	}
+	if dbModel, err = MapSetValues(dbModel, m.sets); err != nil {
+		return 0, err
+	}
+	options = withAttributes(options, RemoveEmptySets(dbModel, m.sets))
+	options = withAttributes(options, normalizedAttributes(m.normalized, dbModel))
+	var res int64
 	if m.versionIndex >= 0 {
-		return PatchOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), MapToDBObject(model, m.maps), m.versionField)
+		res, err = PatchOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), dbModel, m.versionField, options)
+	} else {
+		res, err = PatchOne(ctx, m.Database, m.tableName, m.Keys(), dbModel, options)
 	}
-	return PatchOne(ctx, m.Database, m.tableName, m.Keys(), MapToDBObject(model, m.maps))
+	return m.mapResult(ctx, res, err, options)
 }
 
 func (m *Writer) Save(ctx context.Context, model interface{}) (int64, error) {
+	return m.SaveWithOptions(ctx, model, WriteOptions{})
+}
+func (m *Writer) SaveWithOptions(ctx context.Context, model interface{}, options WriteOptions) (int64, error) {
+	options = m.withOutbox(options)
+	options = m.withSoftDelete(options)
+	options = withAttributes(options, normalizeModel(m.normalized, model))
+	var res int64
+	var err error
+	if m.Audit != nil && len(m.Audit.Protected(m.modelType)) > 0 {
+		if m.versionIndex >= 0 {
+			ok, er1 := m.Exist(ctx, getIdValueFromModel(model, m.Keys()))
+			if er1 != nil {
+				return 0, er1
+			}
+			if ok {
+				return m.UpdateWithOptions(ctx, model, options)
+			}
+			return m.InsertWithOptions(ctx, model, options)
+		}
+		if model, err = m.Audit.OnUpdate(ctx, model); err != nil {
+			return 0, err
+		}
+		res, err = UpsertOneWithProtected(ctx, m.Database, m.tableName, m.Keys(), model, m.Audit.Protected(m.modelType), options)
+		return m.mapResult(ctx, res, err, options)
+	}
 	if m.versionIndex >= 0 {
-		return UpsertOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), model, m.versionIndex, m.versionField)
+		res, err = UpsertOneWithVersion(ctx, m.Database, m.tableName, m.Keys(), model, m.versionIndex, m.versionField, options)
+	} else {
+		res, err = UpsertOne(ctx, m.Database, m.tableName, m.Keys(), model, options)
 	}
-	return UpsertOne(ctx, m.Database, m.tableName, m.Keys(), model)
+	return m.mapResult(ctx, res, err, options)
 }
 
 func (m *Writer) Delete(ctx context.Context, id interface{}) (int64, error) {
+	return m.DeleteWithOptions(ctx, id, WriteOptions{})
+}
+func (m *Writer) DeleteWithOptions(ctx context.Context, id interface{}, options WriteOptions) (int64, error) {
+	options = m.withOutbox(options)
+	var res int64
+	var err error
+	if m.SoftDelete != nil {
+		res, err = SoftDeleteOne(ctx, m.Database, m.tableName, m.Keys(), id, m.SoftDelete, options)
+	} else {
+		res, err = DeleteOne(ctx, m.Database, m.tableName, m.Keys(), id, options)
+	}
+	return m.mapResult(ctx, res, err, options)
+}
+
+// Restore undoes the soft delete of the item.
+func (m *Writer) Restore(ctx context.Context, id interface{}) (int64, error) {
+	if m.SoftDelete == nil {
+		return 0, fmt.Errorf("soft delete is not enabled")
+	}
+	return RestoreOne(ctx, m.Database, m.tableName, m.Keys(), id, m.SoftDelete)
+}
+
+// Purge deletes the item physically, even in soft delete mode.
+func (m *Writer) Purge(ctx context.Context, id interface{}) (int64, error) {
 	return DeleteOne(ctx, m.Database, m.tableName, m.Keys(), id)
 }
+
+// updateProtected replaces the item without overwriting the protected attributes, with the optimistic lock of the version field if any.
+func (m *Writer) updateProtected(ctx context.Context, model interface{}, protected []string, options WriteOptions) (int64, error) {
+	if m.versionIndex < 0 {
+		return UpdateOneWithProtected(ctx, m.Database, m.tableName, m.Keys(), model, protected, options)
+	}
+	version := reflect.Indirect(reflect.ValueOf(model)).Field(m.versionIndex)
+	if !strings.Contains(version.Kind().String(), "int") {
+		return 0, fmt.Errorf("not support type's version: %v", version.Kind().String())
+	}
+	currentVersion := version.Int()
+	values := map[string]*dynamodb.AttributeValue{m.versionField: {N: aws.String(strconv.FormatInt(currentVersion+1, 10))}}
+	condition := And(versionEqual(m.versionField, currentVersion), options.Condition)
+	res, err := putProtected(ctx, m.Database, m.tableName, m.Keys(), model, protected, values, true, condition, versionFailed(m.versionField, currentVersion), options)
+	if err != nil && err.Error() == WrongVersion {
+		return -1, err
+	}
+	return res, err
+}
+
+func (m *Writer) withOutbox(options WriteOptions) WriteOptions {
+	if len(options.Messages) > 0 && options.Outbox == nil {
+		options.Outbox = m.Outbox
+	}
+	return options
+}
+
+// withSoftDelete makes the writes treat the soft deleted items as missing, an insert replaces a soft deleted item.
+func (m *Writer) withSoftDelete(options WriteOptions) WriteOptions {
+	if options.SoftDelete == nil {
+		options.SoftDelete = m.SoftDelete
+	}
+	return options
+}
+
+func withAttributes(options WriteOptions, attributes map[string]interface{}) WriteOptions {
+	if len(attributes) == 0 {
+		return options
+	}
+	merged := make(map[string]interface{})
+	for k, v := range options.Attributes {
+		merged[k] = v
+	}
+	for k, v := range attributes {
+		merged[k] = v
+	}
+	options.Attributes = merged
+	return options
+}
+
+// mapResult applies the Map function of the loader to the item image returned by a write.
+// If Map returns another object, it is copied into the result.
+func (m *Writer) mapResult(ctx context.Context, res int64, err error, options WriteOptions) (int64, error) {
+	if err != nil || m.Map == nil || options.Result == nil || len(options.ReturnValues) == 0 {
+		return res, err
+	}
+	mapped, er2 := m.Map(ctx, options.Result)
+	if er2 != nil || mapped == nil {
+		return res, er2
+	}
+	return res, setResult(options.Result, mapped)
+}
+
+func setResult(result interface{}, value interface{}) error {
+	dst := reflect.ValueOf(result)
+	src := reflect.ValueOf(value)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return fmt.Errorf("result must be a non nil pointer")
+	}
+	if src.Kind() == reflect.Ptr {
+		if src.Pointer() == dst.Pointer() {
+			return nil
+		}
+		if !src.IsNil() && src.Elem().Type().AssignableTo(dst.Elem().Type()) {
+			dst.Elem().Set(src.Elem())
+			return nil
+		}
+	}
+	if src.Type().AssignableTo(dst.Elem().Type()) {
+		dst.Elem().Set(src)
+		return nil
+	}
+	return fmt.Errorf("cannot set the result of type %v with the mapped value of type %v", dst.Elem().Type(), src.Type())
+}
+
+func (m *Writer) AddToSet(ctx context.Context, id interface{}, field string, values interface{}) (int64, error) {
+	name := m.attribute(field)
+	set, err := buildSet(values, m.sets[name])
+	if err != nil {
+		return 0, err
+	}
+	return AddToSet(ctx, m.Database, m.tableName, m.Keys(), id, name, set)
+}
+
+func (m *Writer) DeleteFromSet(ctx context.Context, id interface{}, field string, values interface{}) (int64, error) {
+	name := m.attribute(field)
+	set, err := buildSet(values, m.sets[name])
+	if err != nil {
+		return 0, err
+	}
+	return DeleteFromSet(ctx, m.Database, m.tableName, m.Keys(), id, name, set)
+}
+
+func (m *Writer) AppendToList(ctx context.Context, id interface{}, field string, values interface{}) (int64, error) {
+	return AppendToList(ctx, m.Database, m.tableName, m.Keys(), id, m.attribute(field), values)
+}
+
+func (m *Writer) PrependToList(ctx context.Context, id interface{}, field string, values interface{}) (int64, error) {
+	return PrependToList(ctx, m.Database, m.tableName, m.Keys(), id, m.attribute(field), values)
+}
+
+func (m *Writer) RemoveFromList(ctx context.Context, id interface{}, field string, indexes ...int) (int64, error) {
+	return RemoveFromList(ctx, m.Database, m.tableName, m.Keys(), id, m.attribute(field), indexes...)
+}
+
+func (m *Writer) attribute(field string) string {
+	if name, ok := m.maps[field]; ok {
+		return name
+	}
+	return field
+}
@@ -0,0 +1,215 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type product struct {
+	Id     string   `json:"id" dynamodbav:"id"`
+	Name   string   `json:"name" dynamodbav:"name"`
+	Tags   []string `json:"tags" dynamodbav:"tags,stringset"`
+	Sizes  []int    `json:"sizes" dynamodbav:"sizes,numberset"`
+	Images []string `json:"images" dynamodbav:"images"`
+}
+
+func TestBuildSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  interface{}
+		setType string
+		set     *dynamodb.AttributeValue
+		err     bool
+	}{
+		{"strings", []string{"a", "b"}, "", &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"a", "b"})}, false},
+		{"numbers", []int{1, 2}, "", &dynamodb.AttributeValue{NS: aws.StringSlice([]string{"1", "2"})}, false},
+		{"single value", "a", StringSet, &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"a"})}, false},
+		{"binaries from base64", []interface{}{"AQI="}, BinarySet, &dynamodb.AttributeValue{BS: [][]byte{{1, 2}}}, false},
+		{"mixed elements", []interface{}{"a", 1}, "", nil, true},
+		{"wrong set type", []string{"a"}, NumberSet, nil, true},
+		{"empty", []string{}, StringSet, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := buildSet(tt.values, tt.setType)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(set, tt.set) {
+				t.Errorf("set = %v, want %v", set, tt.set)
+			}
+		})
+	}
+}
+
+func TestMapSetValues(t *testing.T) {
+	sets := MakeSetFields(reflect.TypeOf(product{}))
+	if want := map[string]string{"tags": StringSet, "sizes": NumberSet}; !reflect.DeepEqual(sets, want) {
+		t.Fatalf("MakeSetFields = %v, want %v", sets, want)
+	}
+	model := map[string]interface{}{"id": "p1", "tags": []interface{}{}, "sizes": []interface{}{float64(40)}, "images": []interface{}{}}
+	model, err := MapSetValues(model, sets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := RemoveEmptySets(model, sets)
+	if want := map[string]interface{}{"tags": nil}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if _, ok := model["tags"]; ok {
+		t.Error("the empty set is still written")
+	}
+	if v, ok := model["sizes"].(attributeValue); !ok || !reflect.DeepEqual(v.value.NS, aws.StringSlice([]string{"40"})) {
+		t.Errorf("sizes = %v, want a number set", model["sizes"])
+	}
+	if _, ok := model["images"].([]interface{}); !ok {
+		t.Errorf("images = %v, a list is not a set", model["images"])
+	}
+}
+
+func TestWriterSetsAndLists(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(w *Writer) (int64, error)
+		update string
+		values []string
+	}{
+		{"patch with an empty set", func(w *Writer) (int64, error) {
+			return w.Patch(context.Background(), map[string]interface{}{"id": "p1", "name": "shirt", "tags": []interface{}{}})
+		}, "REMOVE #1\nSET #2 = :0\n", []string{"shirt"}},
+		{"add to set", func(w *Writer) (int64, error) {
+			return w.AddToSet(context.Background(), "p1", "tags", []string{"new"})
+		}, "ADD #1 :0\n", []string{"SS:new"}},
+		{"delete from set", func(w *Writer) (int64, error) {
+			return w.DeleteFromSet(context.Background(), "p1", "sizes", []int{40})
+		}, "DELETE #1 :0\n", []string{"NS:40"}},
+		{"append to list", func(w *Writer) (int64, error) {
+			return w.AppendToList(context.Background(), "p1", "images", "a.png")
+		}, "SET #1 = list_append(if_not_exists(#1, :0), :1)\n", []string{"L:0", "L:1"}},
+		{"remove from list", func(w *Writer) (int64, error) {
+			return w.RemoveFromList(context.Background(), "p1", "images", 0, 2)
+		}, "REMOVE #1[0], #1[2]\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDynamoDB(t, nil)
+			w := NewWriter(db, "products", reflect.TypeOf(product{}), "Id", "")
+			if _, err := tt.write(w); err != nil {
+				t.Fatal(err)
+			}
+			requests := fake.Requests("UpdateItem")
+			if len(requests) != 1 {
+				t.Fatalf("%d updates, want 1", len(requests))
+			}
+			var input dynamodb.UpdateItemInput
+			requests[0].Decode(t, &input)
+			if update := aws.StringValue(input.UpdateExpression); update != tt.update {
+				t.Errorf("update = %q, want %q", update, tt.update)
+			}
+			if condition := aws.StringValue(input.ConditionExpression); condition != "attribute_exists (#0)" {
+				t.Errorf("condition = %q, want the item to exist", condition)
+			}
+			var values []string
+			for _, v := range input.ExpressionAttributeValues {
+				switch {
+				case v.NULL != nil:
+					t.Errorf("update writes NULL")
+				case v.S != nil:
+					values = append(values, *v.S)
+				case v.SS != nil:
+					values = append(values, "SS:"+strings.Join(aws.StringValueSlice(v.SS), ","))
+				case v.NS != nil:
+					values = append(values, "NS:"+strings.Join(aws.StringValueSlice(v.NS), ","))
+				case v.L != nil:
+					values = append(values, fmt.Sprintf("L:%d", len(v.L)))
+				}
+			}
+			sort.Strings(values)
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("values = %v, want %v", values, tt.values)
+			}
+		})
+	}
+}
+
+type accountMapper struct {
+	err error
+}
+
+func (m accountMapper) DbToModel(ctx context.Context, model interface{}) (interface{}, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	a := *model.(*account)
+	a.Status = strings.ToUpper(a.Status)
+	return &a, nil
+}
+
+func (m accountMapper) ModelToDb(ctx context.Context, model interface{}) (interface{}, error) {
+	return model, nil
+}
+
+func TestMapResult(t *testing.T) {
+	old := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a1")}, "status": {S: aws.String("old")}}
+	failed := fmt.Errorf("cannot map")
+	tests := []struct {
+		name   string
+		mapper Mapper
+		status string
+		err    error
+	}{
+		{"no map", nil, "old", nil},
+		{"map returns another object", accountMapper{}, "OLD", nil},
+		{"map fails", accountMapper{err: failed}, "old", failed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDynamoDB(t, func(r fakeRequest) interface{} {
+				return &dynamodb.PutItemOutput{Attributes: old}
+			})
+			w := NewWriter(db, "accounts", reflect.TypeOf(account{}), "Id", "", tt.mapper)
+			var result account
+			_, err := w.UpdateWithOptions(context.Background(), &account{Id: "a1", Status: "new"}, WriteOptions{ReturnValues: dynamodb.ReturnValueAllOld, Result: &result})
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if result.Status != tt.status {
+				t.Errorf("result status = %q, want %q", result.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestSetResult(t *testing.T) {
+	var a account
+	var s string
+	tests := []struct {
+		name   string
+		result interface{}
+		value  interface{}
+		err    bool
+	}{
+		{"same pointer", &a, &a, false},
+		{"pointer to the result type", &a, &account{Id: "a2"}, false},
+		{"value of the result type", &a, account{Id: "a3"}, false},
+		{"other type", &a, "a4", true},
+		{"result is not a pointer", a, &account{}, true},
+		{"string", &s, "a5", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setResult(tt.result, tt.value); (err != nil) != tt.err {
+				t.Errorf("err = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+	if a.Id != "a3" || s != "a5" {
+		t.Errorf("results = %q, %q, want a3, a5", a.Id, s)
+	}
+}